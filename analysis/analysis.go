@@ -0,0 +1,129 @@
+// Package analysis checks a layout for problems which the interpreter cannot detect
+// while it is constructing the tracks, e.g. loops that do not close properly
+// or ramps that are too steep.
+// Some checks rely on the footprint of the tracks, i.e. on their centre lines widened by the track bed.
+package analysis
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+
+	"github.com/weistn/ferrovia/errlog"
+	"github.com/weistn/ferrovia/model"
+	"github.com/weistn/ferrovia/model/tracks"
+)
+
+// Config holds the tolerances and design rules used by the analysis.
+// It can be read from a JSON file using the names given below.
+type Config struct {
+	// Connected tracks may be this far apart (in mm) without being reported.
+	GapTolerance float64 `json:"gap"`
+	// The angle (in degree) at which connected tracks meet may deviate this much
+	// from a straight line without being reported.
+	AngleTolerance float64 `json:"angle"`
+	// Tracks with an incline (in percent) larger than this are reported.
+	MaxGrade float64 `json:"grade"`
+	// Width in mm of the track bed, i.e. of the footprint of a track.
+	BedWidth float64 `json:"bed"`
+	// Minimum height difference in mm between tracks which cross each other.
+	MinClearance float64 `json:"clearance"`
+	// Minimum distance in mm between the centre lines of parallel tracks.
+	Spacing float64 `json:"spacing"`
+	// Minimum distance in mm between the track bed and the edge of the ground plates.
+	EdgeMargin float64 `json:"margin"`
+	// Curves with a smaller radius in mm are reported. Zero disables the check.
+	MinRadius float64 `json:"minRadius"`
+	// Minimum radius per layer name. It overrides MinRadius.
+	LayerMinRadius map[string]float64 `json:"layerMinRadius"`
+	// Curves turning in opposite directions must be separated by straight track of this length in mm.
+	// Zero disables the check.
+	StraightBetweenCurves float64 `json:"straightBetweenCurves"`
+	// If true, turnouts connected to curves are reported.
+	NoTurnoutAfterCurve bool `json:"noTurnoutAfterCurve"`
+	// Name of a mark on the main line. Trains leave it in the direction in which the tracks have been laid out.
+	// Tracks which they cannot reach without reversing are reported. The empty string disables the check.
+	MainLine string `json:"mainLine"`
+	// If true, reversing loops are reported.
+	ReversingLoops bool `json:"reversingLoops"`
+	// If true, all problems are reported as errors. Otherwise they are reported as warnings.
+	Strict bool `json:"strict"`
+}
+
+// Returns the configuration used if nothing else has been specified.
+func DefaultConfig() *Config {
+	return &Config{GapTolerance: 1, AngleTolerance: 0.5, MaxGrade: 3, BedWidth: 40, MinClearance: 80, Spacing: 61.6, ReversingLoops: true}
+}
+
+// Reads a configuration from a JSON file. Settings missing in the file keep their current value.
+func (cfg *Config) Load(r io.Reader) error {
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	return dec.Decode(cfg)
+}
+
+// Returns a copy of the configuration with the rules declared by the layout applied.
+func (cfg *Config) withRules(rules *model.Rules) *Config {
+	result := *cfg
+	if rules.MinRadius != 0 {
+		result.MinRadius = rules.MinRadius
+	}
+	if len(rules.LayerMinRadius) != 0 {
+		result.LayerMinRadius = make(map[string]float64)
+		for name, r := range cfg.LayerMinRadius {
+			result.LayerMinRadius[name] = r
+		}
+		for name, r := range rules.LayerMinRadius {
+			result.LayerMinRadius[name] = r
+		}
+	}
+	if rules.StraightBetweenCurves != 0 {
+		result.StraightBetweenCurves = rules.StraightBetweenCurves
+	}
+	if rules.NoTurnoutAfterCurve {
+		result.NoTurnoutAfterCurve = true
+	}
+	if rules.MaxGrade != 0 {
+		result.MaxGrade = rules.MaxGrade
+	}
+	if rules.MainLine != "" {
+		result.MainLine = rules.MainLine
+	}
+	return &result
+}
+
+// Run performs all checks on the model and logs the problems found.
+// The design rules declared by the layout take precedence over cfg.
+func Run(m *model.Model, cfg *Config, log *errlog.ErrorLog) {
+	cfg = cfg.withRules(&m.Rules)
+	CheckConnections(m, cfg, log)
+	CheckGrades(m, cfg, log)
+	CheckClearance(m, cfg, log)
+	CheckCollisions(m, cfg, log)
+	CheckGround(m, cfg, log)
+	CheckRadius(m, cfg, log)
+	CheckCurves(m, cfg, log)
+	CheckReachability(m, cfg, log)
+}
+
+func (cfg *Config) report(log *errlog.ErrorLog, code errlog.ErrorCode, loc errlog.LocationRange, args ...string) *errlog.Error {
+	if cfg.Strict {
+		return log.LogError(code, loc, args...)
+	}
+	return log.LogWarning(code, loc, args...)
+}
+
+// Returns all tracks of all layers. The layers are sorted by name such that
+// problems are always reported in the same order.
+func allTracks(m *model.Model) []*tracks.Track {
+	var names []string
+	for name := range m.Tracks.Layers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var result []*tracks.Track
+	for _, name := range names {
+		result = append(result, m.Tracks.Layers[name].Tracks...)
+	}
+	return result
+}
@@ -0,0 +1,250 @@
+package analysis
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/weistn/ferrovia/errlog"
+	"github.com/weistn/ferrovia/interpreter"
+	"github.com/weistn/ferrovia/model"
+	"github.com/weistn/ferrovia/model/tracks"
+	"github.com/weistn/ferrovia/parser"
+)
+
+const loopData = `tracks {
+	@(0 mm, 0 mm, 0 mm, 0 deg)
+	Loop
+	11 * R6
+	%v
+	Loop
+}
+tracks Loop {
+	R6
+}
+`
+
+func check(t *testing.T, data string, cfg *Config) *errlog.ErrorLog {
+	tracks.InitRoco()
+	e := errlog.NewErrorLog()
+	fileId := e.AddFile(errlog.NewSourceFile("data"))
+	p := parser.NewParser(e)
+	file := p.Parse(fileId, data)
+	b := interpreter.NewInterpreter(e)
+	m := b.ProcessStatics(file)
+	if e.HasErrors() {
+		t.Fatal(e.ToString())
+	}
+	Run(m, cfg, e)
+	return e
+}
+
+func checkLoop(t *testing.T, straight string, cfg *Config) *errlog.ErrorLog {
+	return check(t, strings.Replace(loopData, "%v", straight, 1), cfg)
+}
+
+func TestLoopClosure(t *testing.T) {
+	e := checkLoop(t, "", DefaultConfig())
+	if e.HasErrors() || e.HasWarnings() {
+		t.Fatal("Loop should close: " + e.ToString())
+	}
+	e = checkLoop(t, "G1", DefaultConfig())
+	if e.HasErrors() || !e.HasWarnings() {
+		t.Fatal("Expected a gap warning")
+	}
+	e = checkLoop(t, "G1", &Config{GapTolerance: 1, AngleTolerance: 0.5, Strict: true})
+	if !strings.Contains(e.ToString(), "Gap of 230.0 mm") {
+		t.Fatal("Expected a gap error: " + e.ToString())
+	}
+}
+
+// Two straight lines crossing each other.
+// The second one starts with a ramp.
+const crossingData = `tracks {
+	@(0 mm, 1000 mm, 0 mm, 90 deg)
+	4 * G1
+}
+tracks {
+	@(500 mm, 500 mm, 0 mm, 180 deg)
+	G1
+	height(%v)
+	4 * G1
+}
+`
+
+func TestClearance(t *testing.T) {
+	strict := DefaultConfig()
+	strict.Strict = true
+	strict.MaxGrade = 100
+	e := check(t, strings.Replace(crossingData, "%v", "100 mm", 1), strict)
+	if e.HasErrors() {
+		t.Fatal("Unexpected clearance error: " + e.ToString())
+	}
+	e = check(t, strings.Replace(crossingData, "%v", "50 mm", 1), strict)
+	if str := e.ToString(); !strings.Contains(str, "Only 50.0 mm clearance") || !strings.Contains(str, "see data 9:6") {
+		t.Fatal("Expected a clearance error: " + str)
+	}
+}
+
+// A turnout whose branch runs parallel to the main line
+// and a straight track at a distance of %v.
+const spacingData = `tracks {
+	@(0 mm, 1000 mm, 0 mm, 90 deg)
+	WR15 {
+		right { L9 G1 }
+	}
+	G1 G1
+}
+tracks {
+	@(0 mm, %v, 0 mm, 90 deg)
+	G1 G1
+}
+`
+
+func TestCollisions(t *testing.T) {
+	strict := DefaultConfig()
+	strict.Strict = true
+	e := check(t, strings.Replace(spacingData, "%v", "800 mm", 1), strict)
+	if e.HasErrors() {
+		t.Fatal("Unexpected collision error: " + e.ToString())
+	}
+	e = check(t, strings.Replace(spacingData, "%v", "960 mm", 1), strict)
+	if str := e.ToString(); !strings.Contains(str, "are only 40.0 mm apart") {
+		t.Fatal("Expected tracks which are too close: " + str)
+	}
+	e = check(t, strings.Replace(spacingData, "%v", "1000 mm", 1), strict)
+	if str := e.ToString(); !strings.Contains(str, "overlap") {
+		t.Fatal("Expected overlapping tracks: " + str)
+	}
+}
+
+// Two ground plates next to each other and a straight line of %v tracks crossing both.
+const groundData = `ground {
+	top(0 mm)
+	left(0 mm)
+	width(1000 mm)
+	height(500 mm)
+}
+ground {
+	top(0 mm)
+	left(1000 mm)
+	polygon([0 mm, 0 mm], [500 mm, 0 mm], [500 mm, 500 mm], [0 mm, 300 mm])
+}
+tracks {
+	@(100 mm, 100 mm, 0 mm, 90 deg)
+	%v * G1
+}
+`
+
+func TestGround(t *testing.T) {
+	strict := DefaultConfig()
+	strict.Strict = true
+	e := check(t, strings.Replace(groundData, "%v", "5", 1), strict)
+	if e.HasErrors() {
+		t.Fatal("Unexpected ground error: " + e.ToString())
+	}
+	e = check(t, strings.Replace(groundData, "%v", "7", 1), strict)
+	if str := e.ToString(); !strings.Contains(str, "hangs over the edge") {
+		t.Fatal("Expected a track off the ground: " + str)
+	}
+	strict.EdgeMargin = 100
+	e = check(t, strings.Replace(groundData, "%v", "5", 1), strict)
+	if str := e.ToString(); !strings.Contains(str, "hangs over the edge") {
+		t.Fatal("Expected a track too close to the edge: " + str)
+	}
+}
+
+const rulesData = `layer hidden {
+	color("#888888")
+}
+
+rules {
+	minRadius("hidden", 600 mm)
+	straightBetweenCurves(100 mm)
+	noTurnoutAfterCurve
+}
+
+tracks {
+	@(0 mm, 0 mm, 0 mm, 90 deg)
+	G1 R5 L5 G1 R5 G05 L5 R6 WL15 G1
+}
+
+tracks {
+	layer("hidden")
+	@(0 mm, 2000 mm, 0 mm, 90 deg)
+	R5
+}
+`
+
+func TestRules(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Strict = true
+	e := check(t, rulesData, cfg)
+	msg := e.ToString()
+	for _, s := range []string{
+		"data 13:8: The curves R5 and R5 turn in opposite directions with only 0.0 mm of straight track in between",
+		"data 13:24: The curves R5 and R6 turn",
+		"data 13:27: The turnout WL15 is connected to the curve R6",
+		"data 19:2: The radius of 542.8 mm is smaller than the minimum of 600.0 mm",
+	} {
+		if !strings.Contains(msg, s) {
+			t.Fatalf("Unexpected errors: %v", msg)
+		}
+	}
+	// The curves separated by G05 are fine
+	if strings.Count(msg, "opposite directions") != 2 {
+		t.Fatalf("Unexpected errors: %v", msg)
+	}
+
+	// The layout declares no minimum radius for the other layers, hence the configuration applies
+	cfg.MinRadius = 550
+	e = check(t, rulesData, cfg)
+	if strings.Count(e.ToString(), "minimum of 550.0 mm") != 4 {
+		t.Fatalf("Unexpected errors: %v", e.ToString())
+	}
+}
+
+const sidingData = `rules {
+	mainLine("main")
+}
+
+tracks {
+	@(0 mm, 0 mm, 0 mm, 90 deg)
+	"main"
+	G1
+	WR10 {
+		right { G1 }
+	}
+	G1
+	WL10 {
+		backleft { G1 G1 }
+	}
+	G1
+}
+`
+
+func TestReachability(t *testing.T) {
+	// The siding branching off the second turnout can only be entered by reversing
+	cfg := DefaultConfig()
+	cfg.Strict = true
+	e := check(t, sidingData, cfg)
+	if strings.Count(e.ToString(), "cannot be reached") != 1 || !strings.Contains(e.ToString(), "data 14:") {
+		t.Fatal("Expected the siding to be unreachable: " + e.ToString())
+	}
+
+	// A balloon loop: the straight and the branch of the turnout are joined by a curve
+	ts := tracks.NewTrackSystem()
+	l := ts.Layers[""]
+	line, turnout, curve := l.NewTrack("G1"), l.NewTrack("WR10"), l.NewTrack("R10")
+	line.Connection(1).Connect(turnout.Connection(0))
+	turnout.Connection(1).Connect(curve.Connection(0))
+	curve.Connection(1).Connect(turnout.Connection(2))
+	r := Reach(&model.Model{Tracks: ts}, "")
+	if len(r.ReversingLoops) != 1 || len(r.ReversingLoops[0]) != 2 {
+		t.Fatalf("Expected one reversing loop, got %v", r.ReversingLoops)
+	}
+	for _, track := range r.ReversingLoops[0] {
+		if track == line {
+			t.Fatal("The line leading to the loop is not part of it")
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package analysis
+
+import (
+	"math"
+
+	"github.com/weistn/ferrovia/errlog"
+	"github.com/weistn/ferrovia/model"
+)
+
+// CheckClearance reports tracks which cross each other on different levels
+// with a height difference below cfg.MinClearance.
+// Tracks crossing on the same level are left to CheckCollisions.
+func CheckClearance(m *model.Model, cfg *Config, log *errlog.ErrorLog) {
+	fs := footprints(allTracks(m))
+	reported := make(map[[2]errlog.LocationRange]bool)
+	for i, f1 := range fs {
+		for _, f2 := range fs[i+1:] {
+			if !f1.near(f2, cfg.BedWidth) || adjacent(f1.track, f2.track) {
+				continue
+			}
+			clearance, ok := crossing(f1, f2, cfg.BedWidth)
+			if !ok || clearance <= levelTolerance || clearance >= cfg.MinClearance {
+				continue
+			}
+			key := [2]errlog.LocationRange{f1.track.SourceLocation, f2.track.SourceLocation}
+			if reported[key] {
+				continue
+			}
+			reported[key] = true
+			err := cfg.report(log, errlog.ErrorInsufficientClearance, f1.track.SourceLocation, formatFloat(clearance), f1.track.Geometry.Name, f2.track.Geometry.Name)
+			err.AddRelated(f2.track.SourceLocation)
+		}
+	}
+}
+
+// Returns the smallest height difference at which the footprints overlap.
+// Returns false if they do not overlap.
+func crossing(f1 *footprint, f2 *footprint, width float64) (float64, bool) {
+	clearance := math.Inf(1)
+	for i := range f1.segments {
+		for j := range f2.segments {
+			dist, p1, p2 := f1.segments[i].distance(&f2.segments[j])
+			if dist < width {
+				clearance = math.Min(clearance, math.Abs(p1[2]-p2[2]))
+			}
+		}
+	}
+	return clearance, !math.IsInf(clearance, 1)
+}
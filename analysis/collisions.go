@@ -0,0 +1,94 @@
+package analysis
+
+import (
+	"math"
+
+	"github.com/weistn/ferrovia/errlog"
+	"github.com/weistn/ferrovia/model"
+	"github.com/weistn/ferrovia/model/tracks"
+)
+
+// Parallel tracks may be this much (in mm) closer than cfg.Spacing without being reported.
+// A Roco turnout followed by a counter curve yields a spacing of 58.4 mm instead of 61.6 mm.
+const spacingTolerance = 3.5
+
+// Tracks are considered parallel if their directions differ by at most this angle in degree.
+const parallelAngle = 10
+
+// CheckCollisions reports tracks on the same level whose footprints overlap
+// and parallel tracks which are closer to each other than cfg.Spacing.
+// Tracks which are connected directly or via a common track are not compared,
+// and neither are crossings, since their routes overlap by construction.
+// Tracks which merely touch at their ends are not reported either.
+func CheckCollisions(m *model.Model, cfg *Config, log *errlog.ErrorLog) {
+	fs := footprints(allTracks(m))
+	reported := make(map[[2]errlog.LocationRange]bool)
+	for i, f1 := range fs {
+		if isCrossing(f1.track.Geometry) {
+			continue
+		}
+		for _, f2 := range fs[i+1:] {
+			if isCrossing(f2.track.Geometry) || !f1.near(f2, cfg.Spacing) || adjacent(f1.track, f2.track) {
+				continue
+			}
+			overlap, dist := collision(f1, f2, cfg)
+			if !overlap && dist >= cfg.Spacing-spacingTolerance {
+				continue
+			}
+			key := [2]errlog.LocationRange{f1.track.SourceLocation, f2.track.SourceLocation}
+			if reported[key] {
+				continue
+			}
+			reported[key] = true
+			var err *errlog.Error
+			if overlap {
+				err = cfg.report(log, errlog.ErrorTracksOverlap, f1.track.SourceLocation, f1.track.Geometry.Name, f2.track.Geometry.Name)
+			} else {
+				err = cfg.report(log, errlog.ErrorTracksTooClose, f1.track.SourceLocation, formatFloat(dist), f1.track.Geometry.Name, f2.track.Geometry.Name)
+			}
+			err.AddRelated(f2.track.SourceLocation)
+		}
+	}
+}
+
+// Returns true if the footprints overlap on the same level.
+// Otherwise the function returns the smallest distance in the XY plane
+// at which parallel tracks are less than cfg.MinClearance apart in height.
+// Overlapping footprints on different levels are left to CheckClearance.
+func collision(f1 *footprint, f2 *footprint, cfg *Config) (bool, float64) {
+	minDist := math.Inf(1)
+	for i := range f1.segments {
+		for j := range f2.segments {
+			s1, s2 := &f1.segments[i], &f2.segments[j]
+			dist, p1, p2 := s1.distance(s2)
+			dz := math.Abs(p1[2] - p2[2])
+			if dz >= cfg.MinClearance || abutting(f1, p1, f2, p2, cfg) {
+				continue
+			}
+			if dist < cfg.BedWidth {
+				if dz <= levelTolerance {
+					return true, dist
+				}
+				continue
+			}
+			if s1.parallel(s2, parallelAngle) {
+				minDist = math.Min(minDist, dist)
+			}
+		}
+	}
+	return false, minDist
+}
+
+// Returns true if p1 and p2 are close to the ends of both tracks and the ends meet,
+// i.e. the tracks could be connected at this point.
+// Close means closer than the distance of parallel tracks.
+func abutting(f1 *footprint, p1 tracks.Vec3, f2 *footprint, p2 tracks.Vec3, cfg *Config) bool {
+	c1, d1 := f1.nearestConnection(p1)
+	c2, d2 := f2.nearestConnection(p2)
+	return d1 < cfg.Spacing && d2 < cfg.Spacing && c1.Sub(c2).Length() <= cfg.GapTolerance
+}
+
+// Returns true for crossings and double slip switches.
+func isCrossing(g *tracks.TrackGeometry) bool {
+	return g.IncomingConnectionCount > 1
+}
@@ -0,0 +1,51 @@
+package analysis
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/weistn/ferrovia/errlog"
+	"github.com/weistn/ferrovia/model"
+	"github.com/weistn/ferrovia/model/tracks"
+)
+
+// CheckConnections compares the connection points of all pairs of connected tracks.
+// The interpreter places the tracks by walking from the anchors along the connections.
+// When a loop closes, or when tracks placed from different anchors are connected,
+// the connection points do not necessarily meet.
+func CheckConnections(m *model.Model, cfg *Config, log *errlog.ErrorLog) {
+	for _, t := range allTracks(m) {
+		if t.Location == nil {
+			continue
+		}
+		for i := 0; i < t.ConnectionCount(); i++ {
+			opp := t.Connection(i).Opposite
+			// Check each pair only once
+			if opp == nil || opp.Track.Location == nil || opp.Track.Id < t.Id {
+				continue
+			}
+			gap, angle := connectionError(t, i, opp.Track, opp.Track.ConnectionIndex(opp))
+			if gap > cfg.GapTolerance {
+				cfg.report(log, errlog.ErrorConnectionGap, opp.Track.SourceLocation, formatFloat(gap), t.Geometry.Name, opp.Track.Geometry.Name)
+			}
+			if angle > cfg.AngleTolerance {
+				cfg.report(log, errlog.ErrorConnectionAngle, opp.Track.SourceLocation, formatFloat(angle), t.Geometry.Name, opp.Track.Geometry.Name)
+			}
+		}
+	}
+}
+
+// Returns the distance in mm between the two connection points
+// and the angle in degree by which they deviate from a straight line.
+func connectionError(t1 *tracks.Track, con1 int, t2 *tracks.Track, con2 int) (gap float64, angle float64) {
+	pos1, angle1 := t1.Location.Connection(con1, t1.Geometry)
+	pos2, angle2 := t2.Location.Connection(con2, t2.Geometry)
+	gap = pos1.Sub(pos2).Length()
+	// Connected tracks head in opposite directions
+	angle = math.Abs(math.Mod(angle1-angle2+360, 360) - 180)
+	return
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', 1, 64)
+}
@@ -0,0 +1,194 @@
+package analysis
+
+import (
+	"math"
+
+	"github.com/weistn/ferrovia/model/tracks"
+)
+
+// Arcs are approximated by segments of at most this length in mm.
+const segmentLength = 10
+
+// Tracks whose heights differ by less than this (in mm) are on the same level.
+const levelTolerance = 1
+
+// A straight piece of the centre line of a track.
+type segment struct {
+	from tracks.Vec3
+	to   tracks.Vec3
+}
+
+// The centre line of a positioned track and its bounding box in the XY plane.
+type footprint struct {
+	track    *tracks.Track
+	segments []segment
+	min      tracks.Vec2
+	max      tracks.Vec2
+}
+
+// Returns the footprints of all positioned tracks.
+func footprints(ts []*tracks.Track) []*footprint {
+	var result []*footprint
+	for _, t := range ts {
+		if t.Location == nil {
+			continue
+		}
+		f := &footprint{track: t, min: tracks.Vec2{math.Inf(1), math.Inf(1)}, max: tracks.Vec2{math.Inf(-1), math.Inf(-1)}}
+		for _, path := range t.Geometry.Paths {
+			f.addPath(path)
+		}
+		result = append(result, f)
+	}
+	return result
+}
+
+// Splits a path into segments.
+// The height of a point depends on its distance from the start of the path,
+// since paths start at the incoming side of the track.
+func (f *footprint) addPath(path tracks.ITrackGeometryPath) {
+	l := f.track.Location
+	length := f.track.Geometry.Length()
+	height := func(s float64) float64 {
+		return l.Center[2] + l.Incline/100*(s-length/2)
+	}
+	var points []tracks.Vec3
+	switch p := path.(type) {
+	case *tracks.TrackGeometryLine:
+		from := l.Center.Add2(p.Anchor.Position.Rotate(l.Rotation))
+		to := from.Add2(tracks.Vec2{0, -p.Size}.Rotate(l.Rotation + p.Anchor.Angle))
+		from[2] = height(0)
+		to[2] = height(p.Size)
+		points = []tracks.Vec3{from, to}
+	case *tracks.TrackGeometryArc:
+		from := l.Center.Add2(p.Anchor.Position.Rotate(l.Rotation))
+		a := (l.Rotation + p.Anchor.Angle) * math.Pi / 180
+		cx := from[0] + math.Cos(a)*p.Radius
+		cy := from[1] + math.Sin(a)*p.Radius
+		arcLength := 2 * math.Pi * p.Radius * p.TrackAngle / 360
+		n := int(math.Ceil(arcLength / segmentLength))
+		for i := 0; i <= n; i++ {
+			phi := a + math.Pi + p.TrackAngle*math.Pi/180*float64(i)/float64(n)
+			points = append(points, tracks.Vec3{cx + math.Cos(phi)*p.Radius, cy + math.Sin(phi)*p.Radius, height(arcLength * float64(i) / float64(n))})
+		}
+	}
+	for i, p := range points {
+		f.min = tracks.Vec2{math.Min(f.min[0], p[0]), math.Min(f.min[1], p[1])}
+		f.max = tracks.Vec2{math.Max(f.max[0], p[0]), math.Max(f.max[1], p[1])}
+		if i > 0 {
+			f.segments = append(f.segments, segment{from: points[i-1], to: p})
+		}
+	}
+}
+
+// Returns true if the bounding boxes of both footprints, enlarged by margin, overlap.
+func (f *footprint) near(f2 *footprint, margin float64) bool {
+	return f.min[0]-margin <= f2.max[0] && f2.min[0]-margin <= f.max[0] && f.min[1]-margin <= f2.max[1] && f2.min[1]-margin <= f.max[1]
+}
+
+// Returns the distance of both segments in the XY plane
+// and the closest points on both segments.
+func (s *segment) distance(s2 *segment) (dist float64, p1 tracks.Vec3, p2 tracks.Vec3) {
+	dist = math.Inf(1)
+	// If the segments intersect, the distance is zero
+	if t1, t2, ok := s.intersect(s2); ok {
+		return 0, s.at(t1), s2.at(t2)
+	}
+	// Otherwise the closest points include an end point of one of the segments
+	try := func(a *segment, p tracks.Vec3, first bool) {
+		q := a.at(a.project(p))
+		d := math.Hypot(q[0]-p[0], q[1]-p[1])
+		if d < dist {
+			dist = d
+			if first {
+				p1, p2 = q, p
+			} else {
+				p1, p2 = p, q
+			}
+		}
+	}
+	try(s, s2.from, true)
+	try(s, s2.to, true)
+	try(s2, s.from, false)
+	try(s2, s.to, false)
+	return
+}
+
+// Returns true if both segments are parallel within maxAngle degrees.
+func (s *segment) parallel(s2 *segment, maxAngle float64) bool {
+	dx, dy := s.to[0]-s.from[0], s.to[1]-s.from[1]
+	ex, ey := s2.to[0]-s2.from[0], s2.to[1]-s2.from[1]
+	l := math.Hypot(dx, dy) * math.Hypot(ex, ey)
+	if l == 0 {
+		return false
+	}
+	return math.Abs(dx*ey-dy*ex)/l <= math.Sin(maxAngle*math.Pi/180)
+}
+
+// Returns the parameters of the intersection point in the XY plane, if any.
+func (s *segment) intersect(s2 *segment) (float64, float64, bool) {
+	dx, dy := s.to[0]-s.from[0], s.to[1]-s.from[1]
+	ex, ey := s2.to[0]-s2.from[0], s2.to[1]-s2.from[1]
+	det := dx*ey - dy*ex
+	if det == 0 {
+		return 0, 0, false
+	}
+	fx, fy := s2.from[0]-s.from[0], s2.from[1]-s.from[1]
+	t1 := (fx*ey - fy*ex) / det
+	t2 := (fx*dy - fy*dx) / det
+	if t1 < 0 || t1 > 1 || t2 < 0 || t2 > 1 {
+		return 0, 0, false
+	}
+	return t1, t2, true
+}
+
+// Returns the parameter of the point on the segment which is closest to p in the XY plane.
+func (s *segment) project(p tracks.Vec3) float64 {
+	dx, dy := s.to[0]-s.from[0], s.to[1]-s.from[1]
+	l := dx*dx + dy*dy
+	if l == 0 {
+		return 0
+	}
+	t := ((p[0]-s.from[0])*dx + (p[1]-s.from[1])*dy) / l
+	return math.Max(0, math.Min(1, t))
+}
+
+func (s *segment) at(t float64) tracks.Vec3 {
+	return tracks.Vec3{s.from[0] + t*(s.to[0]-s.from[0]), s.from[1] + t*(s.to[1]-s.from[1]), s.height(t)}
+}
+
+func (s *segment) height(t float64) float64 {
+	return s.from[2] + t*(s.to[2]-s.from[2])
+}
+
+// Returns the connection point of the track which is closest to p in the XY plane.
+func (f *footprint) nearestConnection(p tracks.Vec3) (tracks.Vec3, float64) {
+	var result tracks.Vec3
+	dist := math.Inf(1)
+	for i := 0; i < f.track.ConnectionCount(); i++ {
+		pos, _ := f.track.Location.Connection(i, f.track.Geometry)
+		if d := math.Hypot(pos[0]-p[0], pos[1]-p[1]); d < dist {
+			result, dist = pos, d
+		}
+	}
+	return result, dist
+}
+
+// Returns true if the tracks are connected with each other or with a common track.
+// Such tracks are close to each other by construction.
+func adjacent(t1 *tracks.Track, t2 *tracks.Track) bool {
+	for i := 0; i < t1.ConnectionCount(); i++ {
+		opp := t1.Connection(i).Opposite
+		if opp == nil {
+			continue
+		}
+		if opp.Track == t2 {
+			return true
+		}
+		for j := 0; j < opp.Track.ConnectionCount(); j++ {
+			if opp2 := opp.Track.Connection(j).Opposite; opp2 != nil && opp2.Track == t2 {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,63 @@
+package analysis
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/weistn/ferrovia/errlog"
+	"github.com/weistn/ferrovia/model"
+)
+
+// Grade describes the height and incline of a single track.
+type Grade struct {
+	Layer string `json:"layer"`
+	Id    int    `json:"id"`
+	Piece string `json:"piece"`
+	Group string `json:"group,omitempty"`
+	// Height in mm at the first and second connection of the track.
+	From float64 `json:"from"`
+	To   float64 `json:"to"`
+	// Incline in percent in the direction from the first to the second connection.
+	Grade float64 `json:"grade"`
+}
+
+// CheckGrades reports all tracks which are steeper than cfg.MaxGrade.
+// Tracks created by the same statement are reported only once.
+func CheckGrades(m *model.Model, cfg *Config, log *errlog.ErrorLog) {
+	var reported errlog.LocationRange
+	for _, t := range allTracks(m) {
+		if t.Location == nil || math.Abs(t.Location.Incline) <= cfg.MaxGrade || t.SourceLocation == reported {
+			continue
+		}
+		reported = t.SourceLocation
+		cfg.report(log, errlog.ErrorGradeTooSteep, t.SourceLocation, formatFloat(math.Abs(t.Location.Incline)), formatFloat(cfg.MaxGrade))
+	}
+}
+
+// Grades returns the grade of all positioned tracks.
+func Grades(m *model.Model) []*Grade {
+	var result []*Grade
+	for _, t := range allTracks(m) {
+		if t.Location == nil {
+			continue
+		}
+		first, second := t.FirstConnection(), t.SecondConnection()
+		from, _ := t.Location.Connection(t.ConnectionIndex(first), t.Geometry)
+		to, _ := t.Location.Connection(t.ConnectionIndex(second), t.Geometry)
+		result = append(result, &Grade{Layer: t.Layer.Name, Id: t.Id, Piece: t.Geometry.Name, Group: t.Group, From: from[2], To: to[2], Grade: t.Incline})
+	}
+	return result
+}
+
+// WriteGradesCSV writes one line per track.
+func WriteGradesCSV(w io.Writer, grades []*Grade) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"layer", "id", "piece", "group", "from", "to", "grade"})
+	for _, g := range grades {
+		cw.Write([]string{g.Layer, strconv.Itoa(g.Id), g.Piece, g.Group, formatFloat(g.From), formatFloat(g.To), formatFloat(g.Grade)})
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,79 @@
+package analysis
+
+import (
+	"math"
+
+	"github.com/weistn/ferrovia/errlog"
+	"github.com/weistn/ferrovia/model"
+)
+
+// Number of points on the edge of the track bed which are tested for each point of the centre line.
+const edgeSamples = 8
+
+// CheckGround reports tracks which are not placed completely on the ground plates.
+// The track bed must keep a distance of cfg.EdgeMargin to the edges of the ground plates.
+// Nothing is checked if the model does not define any ground plates.
+func CheckGround(m *model.Model, cfg *Config, log *errlog.ErrorLog) {
+	if len(m.GroundPlates) == 0 {
+		return
+	}
+	r := cfg.BedWidth/2 + cfg.EdgeMargin
+	var reported errlog.LocationRange
+	for _, f := range footprints(allTracks(m)) {
+		if f.track.SourceLocation == reported || onGround(m.GroundPlates, f, r) {
+			continue
+		}
+		reported = f.track.SourceLocation
+		cfg.report(log, errlog.ErrorTrackOffGround, f.track.SourceLocation, f.track.Geometry.Name)
+	}
+}
+
+// Returns true if all points within a distance r of the centre line of the track are on the ground.
+// The distance is approximated by testing points on a circle around each point of the centre line.
+func onGround(plates []*model.GroundPlate, f *footprint, r float64) bool {
+	for _, s := range f.segments {
+		length := math.Hypot(s.to[0]-s.from[0], s.to[1]-s.from[1])
+		n := int(math.Ceil(length / segmentLength))
+		if n == 0 {
+			n = 1
+		}
+		for i := 0; i <= n; i++ {
+			p := s.at(float64(i) / float64(n))
+			for j := 0; j < edgeSamples; j++ {
+				a := 2 * math.Pi * float64(j) / edgeSamples
+				if !insideGround(plates, p[0]+r*math.Cos(a), p[1]+r*math.Sin(a)) {
+					return false
+				}
+			}
+		}
+	}
+	return true
+}
+
+// Returns true if the point is inside of at least one of the ground plates.
+func insideGround(plates []*model.GroundPlate, x float64, y float64) bool {
+	for _, g := range plates {
+		if len(g.Polygon) == 0 {
+			if x >= g.Left && x <= g.Left+g.Width && y >= g.Top && y <= g.Top+g.Height {
+				return true
+			}
+		} else if insidePolygon(g.Polygon, x-g.Left, y-g.Top) {
+			return true
+		}
+	}
+	return false
+}
+
+// Implements the even-odd rule.
+func insidePolygon(polygon []model.GroundPoint, x float64, y float64) bool {
+	inside := false
+	j := len(polygon) - 1
+	for i := range polygon {
+		pi, pj := polygon[i], polygon[j]
+		if (pi.Y > y) != (pj.Y > y) && x < (pj.X-pi.X)*(y-pi.Y)/(pj.Y-pi.Y)+pi.X {
+			inside = !inside
+		}
+		j = i
+	}
+	return inside
+}
@@ -0,0 +1,212 @@
+package analysis
+
+import (
+	"github.com/weistn/ferrovia/errlog"
+	"github.com/weistn/ferrovia/model"
+	"github.com/weistn/ferrovia/model/tracks"
+)
+
+// A Segment is a track together with the direction in which a train passes it.
+type Segment struct {
+	Track *tracks.Track
+	// The connection via which the train enters the track.
+	Entry *tracks.TrackConnection
+}
+
+// Reachability describes where trains can go without reversing.
+type Reachability struct {
+	// The segments which trains leaving the main line mark can reach without reversing.
+	// Nil if no main line has been specified.
+	Reachable map[Segment]bool
+	// Tracks which cannot be reached from the main line in any direction.
+	Unreachable []*tracks.Track
+	// The tracks of each reversing loop, i.e. of each loop on which trains turn around without reversing.
+	ReversingLoops [][]*tracks.Track
+}
+
+// Computes the reachability of all tracks. The main line is the name of a mark or the empty string.
+// Trains leave the mark in the direction in which the tracks have been laid out.
+func Reach(m *model.Model, mainLine string) *Reachability {
+	all := allTracks(m)
+	r := &Reachability{}
+	if mark := m.Tracks.GetMark(mainLine); mark != nil && mark.Connection != nil {
+		r.Reachable = make(map[Segment]bool)
+		r.reach(mainLineSegment(mark))
+		for _, t := range all {
+			reached := false
+			for i := 0; i < t.ConnectionCount() && !reached; i++ {
+				reached = r.Reachable[Segment{t, t.Connection(i)}]
+			}
+			if !reached {
+				r.Unreachable = append(r.Unreachable, t)
+			}
+		}
+	}
+	r.ReversingLoops = reversingLoops(all)
+	return r
+}
+
+// Reports tracks which cannot be reached from the main line and reversing loops.
+// An unknown main line mark is not reported here, since it has no location in the layout.
+// The interpreter reports unknown marks named by the rules and the check command those named by its flags.
+func CheckReachability(m *model.Model, cfg *Config, log *errlog.ErrorLog) {
+	if cfg.MainLine == "" && !cfg.ReversingLoops {
+		return
+	}
+	r := Reach(m, cfg.MainLine)
+	// Report each group of connected unreachable tracks once
+	unreachable := make(map[*tracks.Track]bool)
+	for _, t := range r.Unreachable {
+		unreachable[t] = true
+	}
+	for _, t := range r.Unreachable {
+		if !unreachable[t] {
+			continue
+		}
+		cfg.report(log, errlog.ErrorUnreachable, t.SourceLocation, t.Geometry.Name, cfg.MainLine)
+		stack := []*tracks.Track{t}
+		for len(stack) != 0 {
+			next := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			delete(unreachable, next)
+			for i := 0; i < next.ConnectionCount(); i++ {
+				if opp := next.Connection(i).Opposite; opp != nil && unreachable[opp.Track] {
+					stack = append(stack, opp.Track)
+				}
+			}
+		}
+	}
+	if !cfg.ReversingLoops {
+		return
+	}
+	for _, loop := range r.ReversingLoops {
+		// Report the loop at a turnout, where trains enter and leave it
+		t := loop[0]
+		for _, candidate := range loop {
+			if candidate.Geometry.IsTurnout() {
+				t = candidate
+				break
+			}
+		}
+		cfg.report(log, errlog.ErrorReversingLoop, t.SourceLocation, t.Geometry.Name)
+	}
+}
+
+// Returns the segment which a train enters when it leaves the mark in the direction in which the tracks have been laid out.
+// Marks in the middle of a sequence of tracks are located at the last connection of the preceding track.
+func mainLineSegment(mark *tracks.TrackMark) Segment {
+	con := mark.Connection
+	if con == con.Track.FirstConnection() || con.Opposite == nil {
+		return Segment{con.Track, con}
+	}
+	return Segment{con.Opposite.Track, con.Opposite}
+}
+
+// Marks all segments reachable from s.
+func (r *Reachability) reach(s Segment) {
+	stack := []Segment{s}
+	r.Reachable[s] = true
+	for len(stack) != 0 {
+		s := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		for _, next := range successors(s) {
+			if !r.Reachable[next] {
+				r.Reachable[next] = true
+				stack = append(stack, next)
+			}
+		}
+	}
+}
+
+// Returns the segments which a train can enter after passing s.
+func successors(s Segment) []Segment {
+	var result []Segment
+	index := s.Track.ConnectionIndex(s.Entry)
+	for _, o := range s.Track.Geometry.TurnoutOptions {
+		var out *tracks.TrackConnection
+		if o.From == index {
+			out = s.Track.Connection(o.To)
+		} else if o.To == index {
+			out = s.Track.Connection(o.From)
+		}
+		if out != nil && out.Opposite != nil {
+			result = append(result, Segment{out.Opposite.Track, out.Opposite})
+		}
+	}
+	return result
+}
+
+// Returns the reversing loops among the tracks.
+// For two-rail DC, the left rail of each track gets a polarity. Connecting an outgoing to an incoming connection joins
+// left rail to left rail, while connecting two incoming or two outgoing connections joins left rail to right rail.
+// The polarities are assigned along a spanning tree of the tracks. A connection which joins rails of different
+// polarity closes a reversing loop, which consists of the connected tracks and the tree path between them.
+func reversingLoops(all []*tracks.Track) [][]*tracks.Track {
+	polarity := make(map[*tracks.Track]int)
+	parent := make(map[*tracks.Track]*tracks.Track)
+	depth := make(map[*tracks.Track]int)
+	done := make(map[*tracks.TrackConnection]bool)
+	var loops [][]*tracks.Track
+	for _, root := range all {
+		if _, ok := polarity[root]; ok {
+			continue
+		}
+		polarity[root] = 0
+		queue := []*tracks.Track{root}
+		for len(queue) != 0 {
+			t := queue[0]
+			queue = queue[1:]
+			for i := 0; i < t.ConnectionCount(); i++ {
+				con := t.Connection(i)
+				opp := con.Opposite
+				if opp == nil || done[con] {
+					continue
+				}
+				done[con] = true
+				done[opp] = true
+				p := polarity[t]
+				if isIncoming(con) == isIncoming(opp) {
+					p = 1 - p
+				}
+				next := opp.Track
+				if q, ok := polarity[next]; !ok {
+					polarity[next] = p
+					parent[next] = t
+					depth[next] = depth[t] + 1
+					queue = append(queue, next)
+				} else if q != p {
+					loops = append(loops, treeCycle(t, next, parent, depth))
+				}
+			}
+		}
+	}
+	return loops
+}
+
+func isIncoming(con *tracks.TrackConnection) bool {
+	return con.Track.ConnectionIndex(con) < con.Track.Geometry.IncomingConnectionCount
+}
+
+// Returns the tracks on the path between a and b in the spanning tree.
+func treeCycle(a *tracks.Track, b *tracks.Track, parent map[*tracks.Track]*tracks.Track, depth map[*tracks.Track]int) []*tracks.Track {
+	var fromA, fromB []*tracks.Track
+	for depth[a] > depth[b] {
+		fromA = append(fromA, a)
+		a = parent[a]
+	}
+	for depth[b] > depth[a] {
+		fromB = append(fromB, b)
+		b = parent[b]
+	}
+	for a != b {
+		fromA = append(fromA, a)
+		fromB = append(fromB, b)
+		a = parent[a]
+		b = parent[b]
+	}
+	fromA = append(fromA, a)
+	for i := len(fromB) - 1; i >= 0; i-- {
+		fromA = append(fromA, fromB[i])
+	}
+	return fromA
+}
@@ -0,0 +1,124 @@
+package analysis
+
+import (
+	"math"
+
+	"github.com/weistn/ferrovia/errlog"
+	"github.com/weistn/ferrovia/model"
+	"github.com/weistn/ferrovia/model/tracks"
+)
+
+// Heading changes (in degree) smaller than this do not make a track a curve.
+const curveTolerance = 0.01
+
+// CheckRadius reports all curves with a radius smaller than the minimum radius of their layer.
+// Tracks created by the same statement are reported only once.
+func CheckRadius(m *model.Model, cfg *Config, log *errlog.ErrorLog) {
+	var reported errlog.LocationRange
+	for _, t := range allTracks(m) {
+		min, ok := cfg.LayerMinRadius[t.Layer.Name]
+		if !ok {
+			min = cfg.MinRadius
+		}
+		if min == 0 || t.SourceLocation == reported {
+			continue
+		}
+		for _, p := range t.Geometry.Paths {
+			if arc, ok := p.(*tracks.TrackGeometryArc); ok && arc.Radius < min {
+				reported = t.SourceLocation
+				cfg.report(log, errlog.ErrorRadiusTooSmall, t.SourceLocation, formatFloat(arc.Radius), formatFloat(min))
+				break
+			}
+		}
+	}
+}
+
+// CheckCurves reports curves turning in opposite directions which are not separated by enough straight track,
+// and turnouts which are connected to curves.
+func CheckCurves(m *model.Model, cfg *Config, log *errlog.ErrorLog) {
+	if cfg.StraightBetweenCurves == 0 && !cfg.NoTurnoutAfterCurve {
+		return
+	}
+	reported := make(map[[2]*tracks.Track]bool)
+	for _, t := range allTracks(m) {
+		if t.Location == nil {
+			continue
+		}
+		if t.ConnectionCount() > 2 {
+			if !cfg.NoTurnoutAfterCurve {
+				continue
+			}
+			for i := 0; i < t.ConnectionCount(); i++ {
+				opp := t.Connection(i).Opposite
+				if opp != nil && opp.Track.Location != nil && turn(opp.Track, opp) != 0 && !reported[[2]*tracks.Track{t, opp.Track}] {
+					reported[[2]*tracks.Track{t, opp.Track}] = true
+					cfg.report(log, errlog.ErrorTurnoutAfterCurve, t.SourceLocation, t.Geometry.Name, opp.Track.Geometry.Name).AddRelated(opp.Track.SourceLocation)
+				}
+			}
+			continue
+		}
+		if cfg.StraightBetweenCurves == 0 {
+			continue
+		}
+		for i := 0; i < 2; i++ {
+			// Follow the straight tracks leaving t via connection i
+			other := t.Connection(1 - i)
+			dir := turn(t, other)
+			if dir == 0 {
+				continue
+			}
+			length := 0.0
+			con := t.Connection(i).Opposite
+			for steps := 0; con != nil && con.Track.Location != nil && con.Track.ConnectionCount() == 2 && steps < len(t.Layer.Tracks); steps++ {
+				next := con.Track
+				d := turn(next, con)
+				if d == 0 && !isStraight(next) {
+					// Neither straight nor a simple curve, e.g. an S-shaped flex track
+					break
+				}
+				if d == -dir && length < cfg.StraightBetweenCurves && !reported[[2]*tracks.Track{next, t}] {
+					reported[[2]*tracks.Track{t, next}] = true
+					cfg.report(log, errlog.ErrorSCurve, next.SourceLocation, formatFloat(length), t.Geometry.Name, next.Geometry.Name).AddRelated(t.SourceLocation)
+				}
+				if d != 0 {
+					break
+				}
+				length += next.Geometry.Length()
+				con = next.Connection(1 - next.ConnectionIndex(con)).Opposite
+			}
+		}
+	}
+}
+
+// Returns 1 if a train entering the track via con turns right, -1 if it turns left and 0 otherwise.
+// The track must have two connections.
+func turn(t *tracks.Track, con *tracks.TrackConnection) int {
+	if t.ConnectionCount() != 2 {
+		return 0
+	}
+	index := t.ConnectionIndex(con)
+	_, in := t.Location.Connection(index, t.Geometry)
+	_, out := t.Location.Connection(1-index, t.Geometry)
+	// The heading increases in right turns
+	delta := math.Mod(out-(in+180)+720, 360)
+	if delta > 180 {
+		delta -= 360
+	}
+	if delta > curveTolerance {
+		return 1
+	}
+	if delta < -curveTolerance {
+		return -1
+	}
+	return 0
+}
+
+// Returns true if the track consists of straight lines only.
+func isStraight(t *tracks.Track) bool {
+	for _, p := range t.Geometry.Paths {
+		if _, ok := p.(*tracks.TrackGeometryArc); ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/weistn/ferrovia/analysis"
+)
+
+func runCheck(args []string) int {
+	fs := flag.NewFlagSet("check", flag.ContinueOnError)
+	quiet := fs.Bool("q", false, "Do not print a summary if the file is free of errors")
+	cfg := analysis.DefaultConfig()
+	fs.Float64Var(&cfg.GapTolerance, "gap", cfg.GapTolerance, "Report gaps between connected tracks larger than this (in mm)")
+	fs.Float64Var(&cfg.AngleTolerance, "angle", cfg.AngleTolerance, "Report connected tracks which meet at an angle larger than this (in degree)")
+	fs.Float64Var(&cfg.MaxGrade, "grade", cfg.MaxGrade, "Report tracks with an incline larger than this (in percent)")
+	fs.Float64Var(&cfg.BedWidth, "bed", cfg.BedWidth, "Width of the track bed (in mm)")
+	fs.Float64Var(&cfg.MinClearance, "clearance", cfg.MinClearance, "Report tracks crossing each other with a smaller height difference (in mm)")
+	fs.Float64Var(&cfg.Spacing, "spacing", cfg.Spacing, "Report tracks closer to each other than this (in mm)")
+	fs.Float64Var(&cfg.EdgeMargin, "margin", cfg.EdgeMargin, "Report tracks closer to the edge of the ground plates than this (in mm)")
+	fs.Float64Var(&cfg.MinRadius, "radius", cfg.MinRadius, "Report curves with a smaller radius (in mm)")
+	fs.StringVar(&cfg.MainLine, "mainline", cfg.MainLine, "Report tracks which trains leaving this `mark` cannot reach without reversing")
+	fs.BoolVar(&cfg.ReversingLoops, "reversing", cfg.ReversingLoops, "Report reversing loops")
+	fs.BoolVar(&cfg.Strict, "strict", false, "Report all problems found by the analysis as errors")
+	fs.Func("rules", "Read tolerances and design rules from a JSON `file`. Later flags override its settings", func(name string) error {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return cfg.Load(f)
+	})
+	filename, ok := parseCommandLine(fs, args)
+	if !ok {
+		return 2
+	}
+
+	m, log, err := loadFile(filename, cfg)
+	if log == nil {
+		// The file could not be read at all
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	if err != nil {
+		// Errors have already been printed by loadFile
+		return 1
+	}
+	if cfg.MainLine != "" && m.Tracks.GetMark(cfg.MainLine) == nil {
+		fmt.Fprintf(os.Stderr, "Unknown mark %v\n", cfg.MainLine)
+		return 1
+	}
+	log.Print()
+	if !*quiet {
+		fmt.Printf("%v: ok\n", filename)
+	}
+	return 0
+}
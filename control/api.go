@@ -0,0 +1,133 @@
+package control
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// Returns the HTTP handler of the JSON API of the layout returned by the function.
+// The function may return nil if there is no layout at the moment. The API serves
+//
+//	GET  /state    the State
+//	POST /turnout  {"name": "W1", "option": 1} selects an option. Without option, the turnout switches to its next option
+//	POST /signal   {"name": "B1", "aspect": "clear"}
+//	POST /block    {"name": "B1", "occupied": true, "train": "ICE"}
+//	POST /loco     {"address": 3, "speed": 40, "forward": true}
+//	POST /function {"address": 3, "function": 0, "on": true}
+//	POST /route    {"from": "a", "to": "b"} sets the shortest route. Without marks, the active route is cleared
+//
+// Changes are answered with the new State.
+func NewAPI(layout func() *Layout) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/state", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if l := current(w, layout); l != nil {
+			writeState(w, l)
+		}
+	})
+	mux.HandleFunc("/turnout", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name   string `json:"name"`
+			Option *int   `json:"option"`
+		}
+		handleChange(w, r, layout, &req, func(l *Layout) error {
+			if req.Option == nil {
+				return l.SwitchTurnout(req.Name)
+			}
+			return l.SetTurnout(req.Name, *req.Option)
+		})
+	})
+	mux.HandleFunc("/signal", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name   string `json:"name"`
+			Aspect string `json:"aspect"`
+		}
+		handleChange(w, r, layout, &req, func(l *Layout) error {
+			return l.SetSignal(req.Name, req.Aspect)
+		})
+	})
+	mux.HandleFunc("/block", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Name     string `json:"name"`
+			Occupied bool   `json:"occupied"`
+			Train    string `json:"train"`
+		}
+		handleChange(w, r, layout, &req, func(l *Layout) error {
+			return l.SetBlock(req.Name, req.Occupied, req.Train)
+		})
+	})
+	mux.HandleFunc("/loco", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Address int  `json:"address"`
+			Speed   int  `json:"speed"`
+			Forward bool `json:"forward"`
+		}
+		handleChange(w, r, layout, &req, func(l *Layout) error {
+			return l.SetLocoSpeed(req.Address, req.Speed, req.Forward)
+		})
+	})
+	mux.HandleFunc("/function", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Address  int  `json:"address"`
+			Function int  `json:"function"`
+			On       bool `json:"on"`
+		}
+		handleChange(w, r, layout, &req, func(l *Layout) error {
+			return l.SetLocoFunction(req.Address, req.Function, req.On)
+		})
+	})
+	mux.HandleFunc("/route", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			From string `json:"from"`
+			To   string `json:"to"`
+		}
+		handleChange(w, r, layout, &req, func(l *Layout) error {
+			return l.SetRoute(req.From, req.To)
+		})
+	})
+	return mux
+}
+
+// Decodes the posted JSON into req and applies the change to the current layout.
+func handleChange(w http.ResponseWriter, r *http.Request, layout func() *Layout, req interface{}, change func(l *Layout) error) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	l := current(w, layout)
+	if l == nil {
+		return
+	}
+	if err := change(l); err != nil {
+		if errors.Is(err, ErrUnknown) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+		} else if errors.Is(err, ErrStation) {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		} else {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+		}
+		return
+	}
+	writeState(w, l)
+}
+
+func current(w http.ResponseWriter, layout func() *Layout) *Layout {
+	l := layout()
+	if l == nil {
+		http.Error(w, "No layout has been loaded", http.StatusServiceUnavailable)
+	}
+	return l
+}
+
+func writeState(w http.ResponseWriter, l *Layout) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(l.State())
+}
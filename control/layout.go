@@ -0,0 +1,580 @@
+package control
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/weistn/ferrovia/control/station"
+	"github.com/weistn/ferrovia/model"
+	"github.com/weistn/ferrovia/model/routing"
+	"github.com/weistn/ferrovia/model/tracks"
+)
+
+// Aspects of a signal.
+const (
+	Stop  = "stop"
+	Clear = "clear"
+)
+
+// Kinds of a Change.
+const (
+	TurnoutChange = "turnout"
+	SignalChange  = "signal"
+	BlockChange   = "block"
+	LocoChange    = "loco"
+	RouteChange   = "route"
+)
+
+var (
+	// Returned, possibly wrapped, for names which do not denote a turnout, signal or block.
+	ErrUnknown = errors.New("unknown")
+	// Returned, possibly wrapped, if the command station fails.
+	ErrStation = errors.New("command station")
+)
+
+// A Layout holds the state of the turnouts, signals and blocks of a model while the layout is being operated.
+// The state of a turnout is the selected option of its track, hence both are always in sync.
+// All methods can be called concurrently.
+type Layout struct {
+	model    *model.Model
+	lock     sync.Mutex
+	turnouts map[string]*tracks.Track
+	// All turnouts of the model, including those without a name.
+	allTurnouts []*tracks.Track
+	// Addresses of the accessory decoders of the turnouts.
+	addresses map[*tracks.Track][]int
+	signals   map[string]*SignalState
+	blocks    map[string]*BlockState
+	locos     map[int]*LocoState
+	// The route which has been set last or nil. It is cleared when one of its turnouts is switched.
+	route     *routing.Route
+	listeners []Listener
+	// Drives the layout or nil.
+	station station.CommandStation
+}
+
+// State is a snapshot of the state of a layout. All lists are sorted by name.
+type State struct {
+	Turnouts []*TurnoutState `json:"turnouts"`
+	Signals  []*SignalState  `json:"signals"`
+	Blocks   []*BlockState   `json:"blocks"`
+	// The locomotives which have received commands, sorted by address.
+	Locos []*LocoState `json:"locos"`
+	// The active route or nil.
+	Route *RouteState `json:"route,omitempty"`
+	// The selected options of all turnouts, including those without a name, which routes can switch as well.
+	Options map[*tracks.Track]int `json:"-"`
+}
+
+type TurnoutState struct {
+	// Name of a mark next to the turnout.
+	Name string `json:"name"`
+	// Index of the selected option.
+	Option int `json:"option"`
+	// Number of options.
+	Options int `json:"options"`
+	// True if the selected option leads trains onto a curved route.
+	Diverging bool `json:"diverging"`
+}
+
+// The signal at the end of a block. It has the name of the block.
+type SignalState struct {
+	Name    string `json:"name"`
+	Address int    `json:"address"`
+	Aspect  string `json:"aspect"`
+}
+
+type BlockState struct {
+	Name     string `json:"name"`
+	Occupied bool   `json:"occupied"`
+	// The train occupying the block if it is known.
+	Train string `json:"train,omitempty"`
+}
+
+type LocoState struct {
+	Address int  `json:"address"`
+	Speed   int  `json:"speed"`
+	Forward bool `json:"forward"`
+	// The functions which are switched on.
+	Functions []int `json:"functions"`
+}
+
+// The route which has been set last. Its turnouts still select the options of the route.
+type RouteState struct {
+	From   string  `json:"from"`
+	To     string  `json:"to"`
+	Length float64 `json:"length"`
+	// The tracks of the route in the order in which trains pass them.
+	Tracks []*tracks.Track `json:"-"`
+}
+
+// A Change names the turnout, signal, block, locomotive or route whose state has changed.
+// Locomotives are named by their address and routes by their start mark.
+type Change struct {
+	Kind string
+	Name string
+}
+
+// A Listener is called after each change with the new state.
+// It is called while the layout is locked, hence it must not call the methods of the layout.
+// It may read the tracks of the model though, since they do not change meanwhile.
+type Listener func(change Change, state *State)
+
+// Creates the initial state of a layout. Turnouts keep their selected option, all signals show stop and all blocks are free.
+// Turnouts are named by the labels of the switches operating them and by the marks next to them.
+func NewLayout(m *model.Model) *Layout {
+	l := &Layout{model: m, turnouts: make(map[string]*tracks.Track), addresses: make(map[*tracks.Track][]int), signals: make(map[string]*SignalState), blocks: make(map[string]*BlockState), locos: make(map[int]*LocoState)}
+	named := make(map[*tracks.Track]bool)
+	for _, sb := range m.Switchboards {
+		for i := range sb.Cells {
+			if c := &sb.Cells[i]; c.Turnout != nil {
+				l.turnouts[c.Text] = c.Turnout
+				named[c.Turnout] = true
+			}
+		}
+	}
+	for _, mark := range m.Tracks.Marks() {
+		if t := mark.Turnout(); t != nil && !named[t] {
+			l.turnouts[mark.Name()] = t
+			named[t] = true
+		}
+	}
+	for _, layer := range m.Tracks.Layers {
+		for _, t := range layer.Tracks {
+			if t.Geometry.IsTurnout() {
+				l.allTurnouts = append(l.allTurnouts, t)
+			}
+		}
+	}
+	for name, addresses := range m.TurnoutAddresses {
+		l.addresses[m.Tracks.GetMark(name).Turnout()] = addresses
+	}
+	for _, b := range m.Blocks {
+		l.blocks[b.Name] = &BlockState{Name: b.Name}
+		if b.Signal != 0 {
+			l.signals[b.Name] = &SignalState{Name: b.Name, Address: b.Signal, Aspect: Stop}
+		}
+	}
+	return l
+}
+
+// Returns the model operated by the layout.
+func (l *Layout) Model() *model.Model {
+	return l.model
+}
+
+// Lets the command station drive the turnouts and signals which have decoder addresses and the locomotives.
+// All of them are set to their current state. If this fails for some of them, the others are set nevertheless
+// and the first error is returned.
+func (l *Layout) Drive(cs station.CommandStation) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.station = cs
+	return l.sendAll(nil)
+}
+
+// Replaces a layout, e.g. when its file has been changed. The layout takes over the command station and the state of the
+// turnouts, signals, blocks and locomotives of the previous layout, which no longer drives the command station.
+// Turnouts, signals and blocks are matched by name, and turnouts keep their option only if their number of options is unchanged.
+// Only the decoder outputs which differ from those sent by the previous layout are sent to the command station.
+// If this fails for some of them, the others are sent nevertheless and the first error is returned.
+func (l *Layout) TakeOver(prev *Layout) error {
+	prev.lock.Lock()
+	cs := prev.station
+	prev.station = nil
+	state := prev.state()
+	// The outputs which the command station has been sent last
+	sent := make(map[int]int)
+	if cs != nil {
+		for t, addresses := range prev.addresses {
+			for i, output := range turnoutOutputs(t, t.SelectedTurnoutOption) {
+				sent[addresses[i]] = output
+			}
+		}
+		for _, sig := range prev.signals {
+			sent[sig.Address] = signalOutput(sig.Aspect)
+		}
+	}
+	prev.lock.Unlock()
+
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	for _, ts := range state.Turnouts {
+		if t, ok := l.turnouts[ts.Name]; ok && len(t.Geometry.TurnoutOptions) == ts.Options {
+			t.SelectedTurnoutOption = ts.Option
+		}
+	}
+	for _, s := range state.Signals {
+		if sig, ok := l.signals[s.Name]; ok {
+			sig.Aspect = s.Aspect
+		}
+	}
+	for _, b := range state.Blocks {
+		if block, ok := l.blocks[b.Name]; ok {
+			block.Occupied = b.Occupied
+			block.Train = b.Train
+		}
+	}
+	for _, loco := range state.Locos {
+		l.locos[loco.Address] = loco
+	}
+	l.station = cs
+	if cs == nil {
+		return nil
+	}
+	return l.sendAll(sent)
+}
+
+// Sends the outputs of all turnouts and signals to the command station, except for those which have been sent already.
+// If this fails for some of them, the others are sent nevertheless and the first error is returned. The caller must hold the lock.
+func (l *Layout) sendAll(sent map[int]int) error {
+	var result error
+	send := func(address int, output int) {
+		if o, ok := sent[address]; ok && o == output {
+			return
+		}
+		if err := stationError(l.station.SetAccessory(address, output)); err != nil && result == nil {
+			result = err
+		}
+	}
+	for t, addresses := range l.addresses {
+		for i, output := range turnoutOutputs(t, t.SelectedTurnoutOption) {
+			send(addresses[i], output)
+		}
+	}
+	for _, sig := range l.signals {
+		send(sig.Address, signalOutput(sig.Aspect))
+	}
+	return result
+}
+
+// Registers a listener which is called after each change.
+func (l *Layout) Listen(listener Listener) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	l.listeners = append(l.listeners, listener)
+}
+
+// Returns the track of a turnout or nil.
+func (l *Layout) Turnout(name string) *tracks.Track {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.turnouts[name]
+}
+
+// Returns a snapshot of the current state.
+func (l *Layout) State() *State {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	return l.state()
+}
+
+func (l *Layout) state() *State {
+	s := &State{Turnouts: []*TurnoutState{}, Signals: []*SignalState{}, Blocks: []*BlockState{}, Options: make(map[*tracks.Track]int)}
+	for _, t := range l.allTurnouts {
+		s.Options[t] = t.SelectedTurnoutOption
+	}
+	for name, t := range l.turnouts {
+		s.Turnouts = append(s.Turnouts, &TurnoutState{Name: name, Option: t.SelectedTurnoutOption, Options: len(t.Geometry.TurnoutOptions), Diverging: t.IsDiverging()})
+	}
+	for _, sig := range l.signals {
+		sig := *sig
+		s.Signals = append(s.Signals, &sig)
+	}
+	for _, b := range l.blocks {
+		b := *b
+		s.Blocks = append(s.Blocks, &b)
+	}
+	if l.route != nil {
+		s.Route = &RouteState{From: l.route.From.Name(), To: l.route.To.Name(), Length: l.route.Length, Tracks: l.route.Tracks}
+	}
+	s.Locos = []*LocoState{}
+	for _, loco := range l.locos {
+		loco := *loco
+		loco.Functions = append([]int{}, loco.Functions...)
+		s.Locos = append(s.Locos, &loco)
+	}
+	sort.Slice(s.Turnouts, func(i, j int) bool { return s.Turnouts[i].Name < s.Turnouts[j].Name })
+	sort.Slice(s.Signals, func(i, j int) bool { return s.Signals[i].Name < s.Signals[j].Name })
+	sort.Slice(s.Blocks, func(i, j int) bool { return s.Blocks[i].Name < s.Blocks[j].Name })
+	sort.Slice(s.Locos, func(i, j int) bool { return s.Locos[i].Address < s.Locos[j].Address })
+	return s
+}
+
+// Selects an option of a turnout.
+func (l *Layout) SetTurnout(name string, option int) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	t, ok := l.turnouts[name]
+	if !ok {
+		return fmt.Errorf("%w turnout %v", ErrUnknown, name)
+	}
+	if option < 0 || option >= len(t.Geometry.TurnoutOptions) {
+		return fmt.Errorf("turnout %v has no option %v", name, option)
+	}
+	if t.SelectedTurnoutOption != option {
+		if err := l.setTurnout(t, option); err != nil {
+			return err
+		}
+		l.notify(Change{Kind: TurnoutChange, Name: name})
+	}
+	return nil
+}
+
+// Selects the next option of a turnout, like a click on its switch.
+func (l *Layout) SwitchTurnout(name string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	t, ok := l.turnouts[name]
+	if !ok {
+		return fmt.Errorf("%w turnout %v", ErrUnknown, name)
+	}
+	if err := l.setTurnout(t, (t.SelectedTurnoutOption+1)%len(t.Geometry.TurnoutOptions)); err != nil {
+		return err
+	}
+	l.notify(Change{Kind: TurnoutChange, Name: name})
+	return nil
+}
+
+// Sends the option to the decoder of the turnout, if it has one, and selects it.
+// A route which requires another option is no longer active. The caller must hold the lock and notify the listeners.
+func (l *Layout) setTurnout(t *tracks.Track, option int) error {
+	if addresses, ok := l.addresses[t]; ok && l.station != nil {
+		for i, output := range turnoutOutputs(t, option) {
+			if err := stationError(l.station.SetAccessory(addresses[i], output)); err != nil {
+				return err
+			}
+		}
+	}
+	t.SelectedTurnoutOption = option
+	if l.route != nil {
+		for _, s := range l.route.Settings {
+			if s.Turnout == t && s.Option != option {
+				l.route = nil
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// Sets the turnouts on the shortest route from one mark to another and makes it the active route.
+// If both marks are empty, the active route is cleared and the turnouts keep their options.
+func (l *Layout) SetRoute(from string, to string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if from == "" && to == "" {
+		if l.route != nil {
+			name := l.route.From.Name()
+			l.route = nil
+			l.notify(Change{Kind: RouteChange, Name: name})
+		}
+		return nil
+	}
+	fromMark := l.model.Tracks.GetMark(from)
+	if fromMark == nil {
+		return fmt.Errorf("%w mark %v", ErrUnknown, from)
+	}
+	toMark := l.model.Tracks.GetMark(to)
+	if toMark == nil {
+		return fmt.Errorf("%w mark %v", ErrUnknown, to)
+	}
+	r := routing.Shortest(fromMark, toMark)
+	if r == nil {
+		return fmt.Errorf("there is no route from %v to %v", from, to)
+	}
+	l.route = nil
+	var err error
+	for _, s := range r.Settings {
+		if s.Turnout.SelectedTurnoutOption != s.Option {
+			if err = l.setTurnout(s.Turnout, s.Option); err != nil {
+				break
+			}
+		}
+	}
+	// The route is active only if all its turnouts have been set
+	if err == nil {
+		l.route = r
+	}
+	l.notify(Change{Kind: RouteChange, Name: from})
+	return err
+}
+
+// Sets the aspect of the signal at the end of a block.
+func (l *Layout) SetSignal(name string, aspect string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	sig, ok := l.signals[name]
+	if !ok {
+		return fmt.Errorf("%w signal %v", ErrUnknown, name)
+	}
+	if aspect != Stop && aspect != Clear {
+		return fmt.Errorf("unknown aspect %v", aspect)
+	}
+	if sig.Aspect != aspect {
+		if l.station != nil {
+			if err := stationError(l.station.SetAccessory(sig.Address, signalOutput(aspect))); err != nil {
+				return err
+			}
+		}
+		sig.Aspect = aspect
+		l.notify(Change{Kind: SignalChange, Name: name})
+	}
+	return nil
+}
+
+// Sets whether a block is occupied and by which train. The train is ignored for free blocks.
+func (l *Layout) SetBlock(name string, occupied bool, train string) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	b, ok := l.blocks[name]
+	if !ok {
+		return fmt.Errorf("%w block %v", ErrUnknown, name)
+	}
+	if !occupied {
+		train = ""
+	}
+	if b.Occupied != occupied || b.Train != train {
+		b.Occupied = occupied
+		b.Train = train
+		l.notify(Change{Kind: BlockChange, Name: name})
+	}
+	return nil
+}
+
+// Sets the speed and direction of a locomotive. This requires a command station.
+func (l *Layout) SetLocoSpeed(address int, speed int, forward bool) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if err := l.locoCommand(func(cs station.CommandStation) error { return cs.SetLocoSpeed(address, speed, forward) }); err != nil {
+		return err
+	}
+	loco := l.loco(address)
+	loco.Speed = speed
+	loco.Forward = forward
+	l.notify(Change{Kind: LocoChange, Name: strconv.Itoa(address)})
+	return nil
+}
+
+// Switches a function of a locomotive. This requires a command station.
+func (l *Layout) SetLocoFunction(address int, function int, on bool) error {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if err := l.locoCommand(func(cs station.CommandStation) error { return cs.SetLocoFunction(address, function, on) }); err != nil {
+		return err
+	}
+	loco := l.loco(address)
+	functions := []int{}
+	for _, f := range loco.Functions {
+		if f != function {
+			functions = append(functions, f)
+		}
+	}
+	if on {
+		functions = append(functions, function)
+		sort.Ints(functions)
+	}
+	loco.Functions = functions
+	l.notify(Change{Kind: LocoChange, Name: strconv.Itoa(address)})
+	return nil
+}
+
+// Returns the state of a locomotive. The caller must hold the lock.
+func (l *Layout) loco(address int) *LocoState {
+	loco, ok := l.locos[address]
+	if !ok {
+		loco = &LocoState{Address: address, Forward: true, Functions: []int{}}
+		l.locos[address] = loco
+	}
+	return loco
+}
+
+// Sends a command to a locomotive. The caller must hold the lock.
+func (l *Layout) locoCommand(cmd func(cs station.CommandStation) error) error {
+	if l.station == nil {
+		return errors.New("there is no command station to drive locomotives")
+	}
+	return stationError(cmd(l.station))
+}
+
+// Wraps the errors of the command station, except for invalid arguments.
+func stationError(err error) error {
+	if err != nil && !errors.Is(err, station.ErrOutOfRange) {
+		return fmt.Errorf("%w: %v", ErrStation, err)
+	}
+	return err
+}
+
+// Returns the outputs of the decoders which select an option of a turnout, one output per decoder.
+// Turnouts with two options have one decoder. Its output is 0 for the straight route and 1 for the diverging route.
+// If both routes are curved or straight, e.g. for a Y turnout, the output is the index of the option.
+// Three-way turnouts have two decoders, which output 0 for the straight route. Otherwise the first decoder
+// outputs 1 for the first diverging route and the second decoder outputs 1 for the second diverging route.
+// Double slip turnouts have two decoders as well. The first one selects the incoming connection
+// and the second one the outgoing connection of the option.
+func turnoutOutputs(t *tracks.Track, option int) []int {
+	g := t.Geometry
+	switch len(g.TurnoutOptions) {
+	case 2:
+		diverging := g.IsDiverging(option)
+		if diverging == g.IsDiverging(1-option) {
+			return []int{option}
+		}
+		if diverging {
+			return []int{1}
+		}
+		return []int{0}
+	case 3:
+		// The first option which is not diverging sets both decoders to 0
+		straight := 0
+		for i := range g.TurnoutOptions {
+			if !g.IsDiverging(i) {
+				straight = i
+				break
+			}
+		}
+		outputs := []int{0, 0}
+		for i, decoder := 0, 0; i < len(g.TurnoutOptions); i++ {
+			if i == straight {
+				continue
+			}
+			if i == option {
+				outputs[decoder] = 1
+			}
+			decoder++
+		}
+		return outputs
+	}
+	first := g.TurnoutOptions[0]
+	o := g.TurnoutOptions[option]
+	outputs := []int{0, 0}
+	if o.From != first.From {
+		outputs[0] = 1
+	}
+	if o.To != first.To {
+		outputs[1] = 1
+	}
+	return outputs
+}
+
+// Returns the decoder output which shows the aspect.
+func signalOutput(aspect string) int {
+	if aspect == Clear {
+		return 1
+	}
+	return 0
+}
+
+// Calls all listeners. The caller must hold the lock.
+func (l *Layout) notify(change Change) {
+	if len(l.listeners) == 0 {
+		return
+	}
+	s := l.state()
+	for _, listener := range l.listeners {
+		listener(change, s)
+	}
+}
@@ -0,0 +1,295 @@
+package control
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/weistn/ferrovia/control/station"
+	"github.com/weistn/ferrovia/errlog"
+	"github.com/weistn/ferrovia/interpreter"
+	"github.com/weistn/ferrovia/model"
+	"github.com/weistn/ferrovia/model/tracks"
+	"github.com/weistn/ferrovia/parser"
+)
+
+const layoutData = `switchboard {
+    ,---@
+@---/----@
+    W1
+}
+
+tracks {
+	@(0 mm, 0 mm, 0 mm, 90 deg)
+	"a"
+	G1
+	"W1"
+	WR10 {
+		right { G1 "c" }
+	}
+	G1
+	"b"
+}
+
+block B1 {
+	from("a")
+	to("W1")
+	signal(5)
+}
+
+decoders {
+	turnout("W1", 7)
+}
+`
+
+func load(t *testing.T, data string) *model.Model {
+	e := errlog.NewErrorLog()
+	fileId := e.AddFile(errlog.NewSourceFile("data"))
+	file := parser.NewParser(e).Parse(fileId, data)
+	m := interpreter.NewInterpreter(e).ProcessStatics(file)
+	if e.HasErrors() {
+		e.Print()
+		t.Fatal("Unexpected errors")
+	}
+	return m
+}
+
+func TestLayout(t *testing.T) {
+	l := NewLayout(load(t, layoutData))
+	var changes []Change
+	l.Listen(func(change Change, state *State) {
+		changes = append(changes, change)
+	})
+	s := l.State()
+	if len(s.Turnouts) != 1 || s.Turnouts[0].Name != "W1" || s.Turnouts[0].Diverging || len(s.Signals) != 1 || s.Signals[0].Aspect != Stop || len(s.Blocks) != 1 {
+		t.Fatalf("Wrong initial state %+v", s)
+	}
+
+	// The state of a turnout is the selected option of its track
+	if err := l.SwitchTurnout("W1"); err != nil {
+		t.Fatal(err)
+	}
+	if l.Turnout("W1").SelectedTurnoutOption != 1 || !l.State().Turnouts[0].Diverging {
+		t.Fatal("The turnout did not switch")
+	}
+	if err := l.SetTurnout("W1", 0); err != nil || l.Turnout("W1").SelectedTurnoutOption != 0 {
+		t.Fatal("The turnout has not been set")
+	}
+	if err := l.SetSignal("B1", Clear); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SetBlock("B1", true, "ICE"); err != nil || l.State().Blocks[0].Train != "ICE" {
+		t.Fatal("The block is not occupied")
+	}
+	if len(changes) != 4 || changes[2] != (Change{SignalChange, "B1"}) {
+		t.Fatalf("Wrong changes %v", changes)
+	}
+
+	if err := l.SetTurnout("W2", 0); !errors.Is(err, ErrUnknown) {
+		t.Fatal("Unknown turnout accepted")
+	}
+	if err := l.SetTurnout("W1", 2); err == nil || errors.Is(err, ErrUnknown) {
+		t.Fatal("Unknown option accepted")
+	}
+	if err := l.SetSignal("B1", "green"); err == nil {
+		t.Fatal("Unknown aspect accepted")
+	}
+}
+
+func TestRoute(t *testing.T) {
+	l := NewLayout(load(t, layoutData))
+	var changes []Change
+	l.Listen(func(change Change, state *State) {
+		changes = append(changes, change)
+	})
+	if err := l.SetRoute("a", "c"); err != nil {
+		t.Fatal(err)
+	}
+	s := l.State()
+	if s.Route == nil || s.Route.To != "c" || len(s.Route.Tracks) != 3 || s.Turnouts[0].Option != 1 {
+		t.Fatalf("The route has not been set: %+v", s.Route)
+	}
+	if len(changes) != 1 || changes[0] != (Change{RouteChange, "a"}) {
+		t.Fatalf("Wrong changes %v", changes)
+	}
+	// Switching a turnout of the route ends it
+	l.SwitchTurnout("W1")
+	if l.State().Route != nil {
+		t.Fatal("The route is still active")
+	}
+	if err := l.SetRoute("a", "b"); err != nil || l.State().Route == nil || l.Turnout("W1").SelectedTurnoutOption != 0 {
+		t.Fatal("The route has not been set")
+	}
+	if err := l.SetRoute("", ""); err != nil || l.State().Route != nil {
+		t.Fatal("The route has not been cleared")
+	}
+	if err := l.SetRoute("a", "x"); !errors.Is(err, ErrUnknown) {
+		t.Fatal("Unknown mark accepted")
+	}
+}
+
+func TestDrive(t *testing.T) {
+	l := NewLayout(load(t, layoutData))
+	if err := l.SetLocoSpeed(3, 40, true); err == nil {
+		t.Fatal("Locomotive driven without command station")
+	}
+	sim := station.NewSimulator()
+	if err := l.Drive(sim); err != nil {
+		t.Fatal(err)
+	}
+	// The station is set to the current state, and then follows all changes
+	if output, ok := sim.Accessory(7); !ok || output != 0 {
+		t.Fatal("The turnout has not been set")
+	}
+	if output, ok := sim.Accessory(5); !ok || output != 0 {
+		t.Fatal("The signal has not been set")
+	}
+	l.SwitchTurnout("W1")
+	l.SetSignal("B1", Clear)
+	if output, _ := sim.Accessory(7); output != 1 {
+		t.Fatal("The turnout did not switch")
+	}
+	if output, _ := sim.Accessory(5); output != 1 {
+		t.Fatal("The signal did not change")
+	}
+	if err := l.SetLocoSpeed(3, 40, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := l.SetLocoFunction(3, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if loco, _ := sim.Loco(3); loco.Speed != 40 || loco.Forward || !loco.Function(0) {
+		t.Fatal("The locomotive has not been driven")
+	}
+	if s := l.State(); len(s.Locos) != 1 || s.Locos[0].Speed != 40 || len(s.Locos[0].Functions) != 1 {
+		t.Fatalf("Wrong locomotive state %+v", s.Locos)
+	}
+	if err := l.SetLocoSpeed(3, 200, true); !errors.Is(err, station.ErrOutOfRange) {
+		t.Fatal("Speed out of range accepted")
+	}
+
+	// Output 0 selects the straight route, which is the second option of left turnouts.
+	// Decoders which fail do not keep the others from being set.
+	data := strings.Replace(strings.Replace(layoutData, "WR10 {\n\t\tright", "WL10 {\n\t\tleft", 1), "signal(5)", "signal(5000)", 1)
+	l = NewLayout(load(t, data))
+	sim = station.NewSimulator()
+	if err := l.Drive(sim); !errors.Is(err, station.ErrOutOfRange) {
+		t.Fatal("Signal address out of range accepted")
+	}
+	if output, ok := sim.Accessory(7); !ok || output != 0 || l.Turnout("W1").SelectedTurnoutOption != 1 {
+		t.Fatal("The turnout has not been set to its straight route")
+	}
+	l.SwitchTurnout("W1")
+	if output, _ := sim.Accessory(7); output != 1 || !l.Turnout("W1").IsDiverging() {
+		t.Fatal("The turnout did not switch to its diverging route")
+	}
+}
+
+// Three-way turnouts and double slip turnouts are switched by two decoders
+func TestTurnoutOutputs(t *testing.T) {
+	data := strings.Replace(strings.Replace(layoutData, "WR10 {\n\t\tright", "DW15 {\n\t\tright", 1), "turnout(\"W1\", 7)", "turnout(\"W1\", 7, 8)", 1)
+	l := NewLayout(load(t, data))
+	sim := station.NewSimulator()
+	if err := l.Drive(sim); err != nil {
+		t.Fatal(err)
+	}
+	for option, outputs := range [][]int{{0, 0}, {1, 0}, {0, 1}} {
+		if err := l.SetTurnout("W1", option); err != nil {
+			t.Fatal(err)
+		}
+		first, _ := sim.Accessory(7)
+		second, _ := sim.Accessory(8)
+		if first != outputs[0] || second != outputs[1] {
+			t.Fatalf("Wrong outputs %v %v for option %v", first, second, option)
+		}
+	}
+
+	dkw := tracks.NewTrackSystem().Layers[""].NewTrack("DKW15")
+	for option, outputs := range [][]int{{0, 0}, {0, 1}, {1, 0}, {1, 1}} {
+		if o := turnoutOutputs(dkw, option); o[0] != outputs[0] || o[1] != outputs[1] {
+			t.Fatalf("Wrong outputs %v for option %v", o, option)
+		}
+	}
+}
+
+// Records the addresses of the accessory commands sent to a simulator.
+type recorder struct {
+	*station.Simulator
+	addresses []int
+}
+
+func (r *recorder) SetAccessory(address int, output int) error {
+	r.addresses = append(r.addresses, address)
+	return r.Simulator.SetAccessory(address, output)
+}
+
+func TestTakeOver(t *testing.T) {
+	prev := NewLayout(load(t, layoutData))
+	cs := &recorder{Simulator: station.NewSimulator()}
+	prev.Drive(cs)
+	prev.SwitchTurnout("W1")
+	prev.SetSignal("B1", Clear)
+	prev.SetBlock("B1", true, "ICE")
+	prev.SetLocoSpeed(3, 40, true)
+
+	// Nothing is sent if the state is unchanged
+	cs.addresses = nil
+	l := NewLayout(load(t, layoutData))
+	if err := l.TakeOver(prev); err != nil {
+		t.Fatal(err)
+	}
+	s := l.State()
+	if !s.Turnouts[0].Diverging || s.Signals[0].Aspect != Clear || !s.Blocks[0].Occupied || s.Blocks[0].Train != "ICE" || len(s.Locos) != 1 || s.Locos[0].Speed != 40 {
+		t.Fatalf("The state has not been taken over %+v", s)
+	}
+	if len(cs.addresses) != 0 {
+		t.Fatalf("Unexpected commands for %v", cs.addresses)
+	}
+	// The previous layout no longer drives the command station
+	prev.SwitchTurnout("W1")
+	if output, _ := cs.Accessory(7); output != 1 || len(cs.addresses) != 0 {
+		t.Fatal("The previous layout switched the turnout")
+	}
+
+	// Decoders with a new address are sent their output
+	next := NewLayout(load(t, strings.Replace(layoutData, "turnout(\"W1\", 7)", "turnout(\"W1\", 8)", 1)))
+	if err := next.TakeOver(l); err != nil {
+		t.Fatal(err)
+	}
+	if output, ok := cs.Accessory(8); !ok || output != 1 || len(cs.addresses) != 1 {
+		t.Fatalf("Wrong commands for %v", cs.addresses)
+	}
+}
+
+func TestAPI(t *testing.T) {
+	l := NewLayout(load(t, layoutData))
+	api := NewAPI(func() *Layout { return l })
+	for _, c := range []struct {
+		method, path, body string
+		code               int
+	}{
+		{http.MethodGet, "/state", "", http.StatusOK},
+		{http.MethodPost, "/turnout", `{"name": "W1"}`, http.StatusOK},
+		{http.MethodPost, "/turnout", `{"name": "W1", "option": 1}`, http.StatusOK},
+		{http.MethodPost, "/signal", `{"name": "B1", "aspect": "clear"}`, http.StatusOK},
+		{http.MethodPost, "/block", `{"name": "B1", "occupied": true}`, http.StatusOK},
+		{http.MethodPost, "/turnout", `{"name": "W2"}`, http.StatusNotFound},
+		{http.MethodPost, "/turnout", `{"name": "W1", "option": 5}`, http.StatusBadRequest},
+		{http.MethodGet, "/turnout", "", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/loco", `{"address": 3, "speed": 40}`, http.StatusBadRequest},
+		{http.MethodPost, "/route", `{"from": "a", "to": "x"}`, http.StatusNotFound},
+		{http.MethodPost, "/route", `{"from": "a", "to": "c"}`, http.StatusOK},
+	} {
+		w := httptest.NewRecorder()
+		api.ServeHTTP(w, httptest.NewRequest(c.method, c.path, strings.NewReader(c.body)))
+		if w.Code != c.code {
+			t.Fatalf("%v %v %v: got %v, expected %v", c.method, c.path, c.body, w.Code, c.code)
+		}
+	}
+	s := l.State()
+	if s.Turnouts[0].Option != 1 || s.Signals[0].Aspect != Clear || !s.Blocks[0].Occupied || s.Route == nil {
+		t.Fatalf("Wrong state %+v", s)
+	}
+}
@@ -0,0 +1,120 @@
+package station
+
+import (
+	"fmt"
+	"io"
+	"sync"
+)
+
+// A Simulator is an in-process command station, which remembers the commands instead of driving a layout.
+// Implements CommandStation.
+type Simulator struct {
+	lock        sync.Mutex
+	accessories map[int]int
+	locos       map[int]*Loco
+	// Receives a line for each command if not nil.
+	Log io.Writer
+}
+
+// The state of a locomotive.
+type Loco struct {
+	Speed   int
+	Forward bool
+	// Bit i is set if function i is on.
+	Functions uint32
+}
+
+func NewSimulator() *Simulator {
+	return &Simulator{accessories: make(map[int]int), locos: make(map[int]*Loco)}
+}
+
+// Returns the output of an accessory decoder. The result is false if no output has been selected yet.
+func (s *Simulator) Accessory(address int) (int, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	output, ok := s.accessories[address]
+	return output, ok
+}
+
+// Returns the state of a locomotive. The result is false if the locomotive has not received any command yet.
+func (s *Simulator) Loco(address int) (Loco, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if l, ok := s.locos[address]; ok {
+		return *l, true
+	}
+	return Loco{}, false
+}
+
+// Returns true if the function is on.
+func (l Loco) Function(function int) bool {
+	return l.Functions&(1<<uint(function)) != 0
+}
+
+func (s *Simulator) SetAccessory(address int, output int) error {
+	if err := checkAccessory(address, output); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.accessories[address] = output
+	s.log("accessory %v output %v", address, output)
+	return nil
+}
+
+func (s *Simulator) SetLocoSpeed(address int, speed int, forward bool) error {
+	if err := checkLoco(address); err != nil {
+		return err
+	}
+	if err := checkSpeed(speed); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	l := s.loco(address)
+	l.Speed = speed
+	l.Forward = forward
+	s.log("loco %v speed %v forward %v", address, speed, forward)
+	return nil
+}
+
+func (s *Simulator) SetLocoFunction(address int, function int, on bool) error {
+	if err := checkLoco(address); err != nil {
+		return err
+	}
+	if err := checkFunction(function); err != nil {
+		return err
+	}
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	l := s.loco(address)
+	if on {
+		l.Functions |= 1 << uint(function)
+	} else {
+		l.Functions &^= 1 << uint(function)
+	}
+	s.log("loco %v function %v on %v", address, function, on)
+	return nil
+}
+
+func (s *Simulator) Close() error {
+	return nil
+}
+
+// Returns the state of a locomotive. The caller must hold the lock.
+func (s *Simulator) loco(address int) *Loco {
+	l, ok := s.locos[address]
+	if !ok {
+		// Locomotives start in forward direction
+		l = &Loco{Forward: true}
+		s.locos[address] = l
+	}
+	return l
+}
+
+// The caller must hold the lock.
+func (s *Simulator) log(format string, args ...interface{}) {
+	if s.Log != nil {
+		fmt.Fprintf(s.Log, format+"\n", args...)
+	}
+}
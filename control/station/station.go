@@ -0,0 +1,64 @@
+// Package station talks to the digital command stations which drive the turnouts, signals and locomotives of a layout.
+package station
+
+import (
+	"errors"
+	"fmt"
+)
+
+// A CommandStation sends commands to the accessory decoders and locomotives of a layout.
+// Addresses start at 1 like the addresses printed on decoders.
+type CommandStation interface {
+	// Switches the output of an accessory decoder, e.g. of a turnout or signal.
+	// Output 0 usually sets a turnout to straight or a signal to stop, output 1 to diverging or clear.
+	SetAccessory(address int, output int) error
+	// Sets the speed of a locomotive in the range 0 to MaxSpeed and its direction.
+	SetLocoSpeed(address int, speed int, forward bool) error
+	// Switches a function of a locomotive, e.g. function 0 for the lights.
+	SetLocoFunction(address int, function int, on bool) error
+	Close() error
+}
+
+// The maximum speed of a locomotive, which uses 128 speed steps.
+const MaxSpeed = 126
+
+// Maximum address of accessory decoders and locomotives.
+const (
+	MaxAccessoryAddress = 2048
+	MaxLocoAddress      = 9999
+	MaxLocoFunction     = 28
+)
+
+// Returned, possibly wrapped, for addresses, outputs, speeds and functions out of range.
+var ErrOutOfRange = errors.New("out of range")
+
+func checkAccessory(address int, output int) error {
+	if address < 1 || address > MaxAccessoryAddress {
+		return fmt.Errorf("accessory address %v %w", address, ErrOutOfRange)
+	}
+	if output != 0 && output != 1 {
+		return fmt.Errorf("accessory output %v %w", output, ErrOutOfRange)
+	}
+	return nil
+}
+
+func checkLoco(address int) error {
+	if address < 1 || address > MaxLocoAddress {
+		return fmt.Errorf("locomotive address %v %w", address, ErrOutOfRange)
+	}
+	return nil
+}
+
+func checkSpeed(speed int) error {
+	if speed < 0 || speed > MaxSpeed {
+		return fmt.Errorf("speed %v %w", speed, ErrOutOfRange)
+	}
+	return nil
+}
+
+func checkFunction(function int) error {
+	if function < 0 || function > MaxLocoFunction {
+		return fmt.Errorf("locomotive function %v %w", function, ErrOutOfRange)
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package station
+
+import (
+	"bytes"
+	"errors"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestZ21(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	z, err := DialZ21(conn.LocalAddr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	z.SwitchTime = 100 * time.Millisecond
+
+	expect := func(msg ...byte) {
+		buf := make([]byte, 64)
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf[:n], msg) {
+			t.Fatalf("Got % x, expected % x", buf[:n], msg)
+		}
+	}
+	// The accessory command is sent while the caller continues
+	start := time.Now()
+	if err := z.SetAccessory(5, 1); err != nil {
+		t.Fatal(err)
+	}
+	if time.Since(start) >= z.SwitchTime {
+		t.Fatal("SetAccessory waited for the switch time")
+	}
+	expect(0x09, 0x00, 0x40, 0x00, 0x53, 0x00, 0x04, 0x89, 0xde)
+	expect(0x09, 0x00, 0x40, 0x00, 0x53, 0x00, 0x04, 0x81, 0xd6)
+	if err := z.SetLocoSpeed(3, 40, true); err != nil {
+		t.Fatal(err)
+	}
+	expect(0x0a, 0x00, 0x40, 0x00, 0xe4, 0x13, 0x00, 0x03, 0xa9, 0x5d)
+	if err := z.SetLocoFunction(1234, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	expect(0x0a, 0x00, 0x40, 0x00, 0xe4, 0xf8, 0xc4, 0xd2, 0x40, 0x4a)
+	if err := z.SetLocoSpeed(3, 127, true); !errors.Is(err, ErrOutOfRange) {
+		t.Fatal("Speed out of range accepted")
+	}
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+	expect(0x04, 0x00, 0x30, 0x00)
+}
+
+func TestSimulator(t *testing.T) {
+	s := NewSimulator()
+	if _, ok := s.Accessory(5); ok {
+		t.Fatal("Unexpected accessory")
+	}
+	s.SetAccessory(5, 1)
+	s.SetLocoSpeed(3, 40, false)
+	s.SetLocoFunction(3, 2, true)
+	if output, ok := s.Accessory(5); !ok || output != 1 {
+		t.Fatal("Wrong accessory output")
+	}
+	if l, ok := s.Loco(3); !ok || l.Speed != 40 || l.Forward || !l.Function(2) || l.Function(0) {
+		t.Fatalf("Wrong locomotive state %+v", l)
+	}
+	if err := s.SetAccessory(0, 1); !errors.Is(err, ErrOutOfRange) {
+		t.Fatal("Address out of range accepted")
+	}
+}
@@ -0,0 +1,197 @@
+package station
+
+import (
+	"encoding/binary"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// The UDP port on which Z21 command stations listen.
+const Z21Port = 21105
+
+// Headers of the Z21 LAN protocol.
+const (
+	z21LanLogoff = 0x30
+	z21LanX      = 0x40
+)
+
+// X-Bus headers of the Z21 LAN protocol.
+const (
+	z21SetTurnout = 0x53
+	z21LocoDrive  = 0xe4
+	// Follows z21LocoDrive to select 128 speed steps.
+	z21Speed128 = 0x13
+	// Follows z21LocoDrive to switch a function.
+	z21Function = 0xf8
+)
+
+// Z21 drives a Roco or Fleischmann Z21 command station via the Z21 LAN protocol over UDP.
+// Implements CommandStation.
+type Z21 struct {
+	conn net.Conn
+	// Time for which the output of an accessory decoder is activated.
+	SwitchTime time.Duration
+	// Guards queue and err.
+	lock sync.Mutex
+	// Accessory commands which have not been sent yet.
+	queue []accessoryCommand
+	// The first error of a queued command, which has not been returned yet.
+	err error
+	// Wakes up the goroutine sending the queued commands. Closed by Close.
+	wake chan struct{}
+	// Closed when the queued commands have been sent after Close.
+	done chan struct{}
+}
+
+type accessoryCommand struct {
+	address int
+	output  int
+}
+
+// Connects to the Z21 at the given host. The port defaults to Z21Port.
+func DialZ21(address string) (*Z21, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, strconv.Itoa(Z21Port))
+	}
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	z := &Z21{conn: conn, SwitchTime: 150 * time.Millisecond, wake: make(chan struct{}, 1), done: make(chan struct{})}
+	go z.sendAccessories()
+	return z, nil
+}
+
+// Queues the command and returns without waiting for the switch time.
+// If a queued command has failed meanwhile, its error is returned instead.
+func (z *Z21) SetAccessory(address int, output int) error {
+	if err := checkAccessory(address, output); err != nil {
+		return err
+	}
+	z.lock.Lock()
+	err := z.err
+	z.err = nil
+	z.queue = append(z.queue, accessoryCommand{address: address, output: output})
+	z.lock.Unlock()
+	select {
+	case z.wake <- struct{}{}:
+	default:
+	}
+	return err
+}
+
+// Sends the queued accessory commands one after the other, since each activates an output for the switch time.
+func (z *Z21) sendAccessories() {
+	defer close(z.done)
+	for range z.wake {
+		for {
+			z.lock.Lock()
+			if len(z.queue) == 0 {
+				z.lock.Unlock()
+				break
+			}
+			cmd := z.queue[0]
+			z.queue = z.queue[1:]
+			z.lock.Unlock()
+			if err := z.sendAccessory(cmd); err != nil {
+				z.lock.Lock()
+				if z.err == nil {
+					z.err = err
+				}
+				z.lock.Unlock()
+			}
+		}
+	}
+}
+
+func (z *Z21) sendAccessory(cmd accessoryCommand) error {
+	// The protocol counts accessory addresses from 0
+	a := cmd.address - 1
+	// The last byte is 10Q0A00P, where A activates and P selects the output
+	if err := z.sendX(z21SetTurnout, byte(a>>8), byte(a), 0x88|byte(cmd.output)); err != nil {
+		return err
+	}
+	time.Sleep(z.SwitchTime)
+	return z.sendX(z21SetTurnout, byte(a>>8), byte(a), 0x80|byte(cmd.output))
+}
+
+func (z *Z21) SetLocoSpeed(address int, speed int, forward bool) error {
+	if err := checkLoco(address); err != nil {
+		return err
+	}
+	if err := checkSpeed(speed); err != nil {
+		return err
+	}
+	// Speed step 1 is the emergency stop
+	v := byte(0)
+	if speed != 0 {
+		v = byte(speed + 1)
+	}
+	if forward {
+		v |= 0x80
+	}
+	msb, lsb := z21LocoAddress(address)
+	return z.sendX(z21LocoDrive, z21Speed128, msb, lsb, v)
+}
+
+func (z *Z21) SetLocoFunction(address int, function int, on bool) error {
+	if err := checkLoco(address); err != nil {
+		return err
+	}
+	if err := checkFunction(function); err != nil {
+		return err
+	}
+	// The last byte is TTNNNNNN, where TT is 0 for off and 1 for on and N is the function
+	v := byte(function)
+	if on {
+		v |= 0x40
+	}
+	msb, lsb := z21LocoAddress(address)
+	return z.sendX(z21LocoDrive, z21Function, msb, lsb, v)
+}
+
+// Sends the queued accessory commands, logs off from the Z21 and closes the connection.
+// Returns the first error of the queued commands, if any.
+func (z *Z21) Close() error {
+	close(z.wake)
+	<-z.done
+	// The goroutine has terminated, hence err is no longer guarded
+	err := z.err
+	if err2 := z.send(z21LanLogoff, nil); err == nil {
+		err = err2
+	}
+	if err2 := z.conn.Close(); err == nil {
+		err = err2
+	}
+	return err
+}
+
+// Returns the two address bytes of a locomotive. Addresses from 128 on are long addresses.
+func z21LocoAddress(address int) (byte, byte) {
+	msb := byte(address >> 8)
+	if address >= 128 {
+		msb |= 0xc0
+	}
+	return msb, byte(address)
+}
+
+// Sends an X-Bus message. It ends in the XOR of all its bytes.
+func (z *Z21) sendX(data ...byte) error {
+	var xor byte
+	for _, b := range data {
+		xor ^= b
+	}
+	return z.send(z21LanX, append(data, xor))
+}
+
+// Sends a message, which starts with its length and header in little endian.
+func (z *Z21) send(header uint16, data []byte) error {
+	msg := make([]byte, 4+len(data))
+	binary.LittleEndian.PutUint16(msg, uint16(len(msg)))
+	binary.LittleEndian.PutUint16(msg[2:], header)
+	copy(msg[4:], data)
+	_, err := z.conn.Write(msg)
+	return err
+}
@@ -58,7 +58,12 @@ func (log *ErrorLog) ErrorToString(err *Error) string {
 	loc := err.Location()
 	file, line, pos := log.Decode(loc.From)
 	//	_, to := l.Resolve(loc.To)
-	return fmt.Sprintf("%v %v:%v: %v", file.Name, line, pos, err.ToString(log))
+	str := fmt.Sprintf("%v %v:%v: %v", file.Name, line, pos, err.ToString(log))
+	for _, r := range err.Related() {
+		file, line, pos := log.Decode(r.From)
+		str += fmt.Sprintf("\n\tsee %v %v:%v", file.Name, line, pos)
+	}
+	return str
 }
 
 func (log *ErrorLog) ToString() string {
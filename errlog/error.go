@@ -22,23 +22,66 @@ const (
 	ErrorUnknownTrackType
 	ErrorTrackConnectedTwice
 	ErrorTrackMarkDefinedTwice
+	ErrorTrackMarkWithoutTrack
 	ErrorTrackPositionedTwice
 	ErrorDuplicateIdentifier
 	ErrorUnknownLayer
 	ErrorNoTrackInRepeatExpression
-	ErrorNamedRailwayUsedTwice
+	ErrorIllegalRepeatCount
+	ErrorRecursiveTracks
 	ErrorTypeMismtach
 	ErrorArgumentCountMismatch
 	ErrorUnknownMethod
 	ErrorNotAMethod
 	ErrorIllegalInThisContext
+	ErrorTurnoutNoFreeConnection
+	ErrorTurnoutNoMiddleConnection
+	ErrorTurnoutFrontAndBack
+	ErrorUnsupportedTurnout
+	ErrorUnsupportedExpression
+	ErrorInternal
+	ErrorHeightWithoutTrack
+	ErrorHeightUnreachable
+	ErrorUnknownTrackSystem
+	ErrorDuplicateTrackSystem
+	ErrorInvalidGeometry
+	ErrorFlexUnconnected
+	ErrorFlexTooTight
+	ErrorFlexNotNeeded
+	ErrorSwitchUnknownMark
+	ErrorSwitchWithoutTurnout
+	ErrorTurnoutBoundTwice
+	ErrorUnknownMark
+	ErrorBlockIncomplete
+	ErrorBlockWithoutRoute
+	ErrorBlockMarkInsideTrack
+	ErrorBlocksOverlap
+	ErrorUnknownBlock
+	ErrorIllegalAddress
+	ErrorDecoderTooManyOptions
+	ErrorDecoderAddressCount
+
+	// Analysis errors
+	ErrorConnectionGap
+	ErrorConnectionAngle
+	ErrorGradeTooSteep
+	ErrorInsufficientClearance
+	ErrorTracksOverlap
+	ErrorTracksTooClose
+	ErrorTrackOffGround
+	ErrorRadiusTooSmall
+	ErrorSCurve
+	ErrorTurnoutAfterCurve
+	ErrorUnreachable
+	ErrorReversingLoop
 )
 
 type Error struct {
 	code     ErrorCode
 	location LocationRange
 	args     []string
-	//	locations []LocationRange
+	// Further locations involved in the error, e.g. the other one of two colliding tracks.
+	related []LocationRange
 }
 
 func NewError(code ErrorCode, loc LocationRange, args ...string) *Error {
@@ -70,7 +113,9 @@ func (e *Error) ToString(log *ErrorLog) string {
 	case ErrorTrackConnectedTwice:
 		return "The track has been connected twice"
 	case ErrorTrackMarkDefinedTwice:
-		return "The mark " + e.args[0] + " has been defined twice on the same track"
+		return "The mark " + e.args[0] + " has been defined twice"
+	case ErrorTrackMarkWithoutTrack:
+		return "The mark " + e.args[0] + " is not next to any track"
 	case ErrorTrackPositionedTwice:
 		return "More than one position has been defined for the track"
 	case ErrorUnknownDirective:
@@ -97,8 +142,10 @@ func (e *Error) ToString(log *ErrorLog) string {
 		return "Unknown layer `" + e.args[0] + "`"
 	case ErrorNoTrackInRepeatExpression:
 		return "A repeat expression requires a track type to repeat"
-	case ErrorNamedRailwayUsedTwice:
-		return fmt.Sprintf("The railway %v has been used twice", e.args[0])
+	case ErrorIllegalRepeatCount:
+		return "The repeat count " + e.args[0] + " is not an integer between 1 and " + e.args[1]
+	case ErrorRecursiveTracks:
+		return fmt.Sprintf("The tracks %v are used recursively", e.args[0])
 	case ErrorUnexpectedEOF:
 		return "Unexpected end of file"
 	case ErrorMalformedLayout:
@@ -113,6 +160,82 @@ func (e *Error) ToString(log *ErrorLog) string {
 		return "Type mismatch"
 	case ErrorIllegalInThisContext:
 		return "The execution of this statement is illegal in the current context"
+	case ErrorTurnoutNoFreeConnection:
+		return "The turnout " + e.args[0] + " has no free connection left"
+	case ErrorTurnoutNoMiddleConnection:
+		return "The turnout " + e.args[0] + " has no middle connection"
+	case ErrorTurnoutFrontAndBack:
+		return "Tracks cannot branch off the turnout " + e.args[0] + " in forward and backward direction at the same time"
+	case ErrorUnsupportedTurnout:
+		return "Turnouts like " + e.args[0] + " are not supported"
+	case ErrorUnsupportedExpression:
+		return "This kind of expression is not supported"
+	case ErrorInternal:
+		return "Internal error: " + e.args[0]
+	case ErrorHeightWithoutTrack:
+		return "No tracks lead to the height of " + e.args[0] + " mm"
+	case ErrorHeightUnreachable:
+		return "The height of " + e.args[0] + " mm cannot be reached, because the tracks leading to it are not positioned from their start"
+	case ErrorUnknownTrackSystem:
+		return "Unknown track system " + e.args[0] + ". Known systems are " + e.args[1]
+	case ErrorDuplicateTrackSystem:
+		return "The track system has already been selected"
+	case ErrorInvalidGeometry:
+		return "The geometry " + e.args[0] + " is invalid: " + e.args[1]
+	case ErrorFlexUnconnected:
+		return "A flex track must join two tracks which are located independently of the flex track"
+	case ErrorFlexTooTight:
+		return "The flex track cannot join its neighbours with curves of at least " + e.args[0] + " mm radius"
+	case ErrorFlexNotNeeded:
+		return "The flex track is not needed, since its neighbours meet already"
+	case ErrorSwitchUnknownMark:
+		return "The switch is labelled " + e.args[0] + ", but there is no mark of this name"
+	case ErrorSwitchWithoutTurnout:
+		return "The mark " + e.args[0] + " is not next to exactly one turnout"
+	case ErrorTurnoutBoundTwice:
+		return "The turnout at mark " + e.args[0] + " is operated by more than one switch"
+	case ErrorUnknownMark:
+		return "Unknown mark `" + e.args[0] + "`"
+	case ErrorBlockIncomplete:
+		return "The block " + e.args[0] + " requires a from and a to mark"
+	case ErrorBlockWithoutRoute:
+		return "The block " + e.args[0] + " cannot be laid out, because there is no route from mark " + e.args[1] + " to mark " + e.args[2]
+	case ErrorBlockMarkInsideTrack:
+		return "The block " + e.args[0] + " cannot end at mark " + e.args[1] + ", because the mark lies inside a track and not between two tracks"
+	case ErrorBlocksOverlap:
+		return "The blocks " + e.args[0] + " and " + e.args[1] + " share the track " + e.args[2]
+	case ErrorUnknownBlock:
+		return "The block is labelled " + e.args[0] + ", but there is no block of this name"
+	case ErrorIllegalAddress:
+		return "The address " + e.args[0] + " is not a positive integer"
+	case ErrorDecoderTooManyOptions:
+		return "The turnout next to mark " + e.args[0] + " has " + e.args[1] + " options, but two accessory decoders can switch four options only"
+	case ErrorDecoderAddressCount:
+		return "The turnout next to mark " + e.args[0] + " has " + e.args[1] + " options and requires " + e.args[2]
+	case ErrorConnectionGap:
+		return "Gap of " + e.args[0] + " mm between the connected tracks " + e.args[1] + " and " + e.args[2]
+	case ErrorConnectionAngle:
+		return "The connected tracks " + e.args[1] + " and " + e.args[2] + " meet at an angle of " + e.args[0] + " degree"
+	case ErrorInsufficientClearance:
+		return "Only " + e.args[0] + " mm clearance between the crossing tracks " + e.args[1] + " and " + e.args[2]
+	case ErrorTracksOverlap:
+		return "The tracks " + e.args[0] + " and " + e.args[1] + " overlap"
+	case ErrorTracksTooClose:
+		return "The tracks " + e.args[1] + " and " + e.args[2] + " are only " + e.args[0] + " mm apart"
+	case ErrorTrackOffGround:
+		return "The track " + e.args[0] + " hangs over the edge of the ground plates"
+	case ErrorRadiusTooSmall:
+		return "The radius of " + e.args[0] + " mm is smaller than the minimum of " + e.args[1] + " mm"
+	case ErrorSCurve:
+		return "The curves " + e.args[1] + " and " + e.args[2] + " turn in opposite directions with only " + e.args[0] + " mm of straight track in between"
+	case ErrorTurnoutAfterCurve:
+		return "The turnout " + e.args[0] + " is connected to the curve " + e.args[1]
+	case ErrorUnreachable:
+		return "The tracks starting with " + e.args[0] + " cannot be reached from the main line at " + e.args[1] + " without reversing"
+	case ErrorReversingLoop:
+		return "The turnout " + e.args[0] + " leads into a reversing loop, which needs an isolated section for two-rail DC"
+	case ErrorGradeTooSteep:
+		return "The grade of " + e.args[0] + " % exceeds the maximum of " + e.args[1] + " %"
 	}
 	println(e.code)
 	panic("Should not happen")
@@ -122,3 +245,14 @@ func (e *Error) ToString(log *ErrorLog) string {
 func (e *Error) Location() LocationRange {
 	return e.location
 }
+
+// AddRelated records another location which is involved in the error.
+func (e *Error) AddRelated(loc LocationRange) *Error {
+	e.related = append(e.related, loc)
+	return e
+}
+
+// Related returns the locations recorded by AddRelated.
+func (e *Error) Related() []LocationRange {
+	return e.related
+}
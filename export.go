@@ -0,0 +1,162 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/weistn/ferrovia/analysis"
+	"github.com/weistn/ferrovia/model"
+	"github.com/weistn/ferrovia/view/bom"
+	"github.com/weistn/ferrovia/view/switchboard"
+	"github.com/weistn/ferrovia/view/tracks2d"
+)
+
+// An export format writes some view of the model to w.
+type exportFormat struct {
+	name        string
+	description string
+	write       func(w io.Writer, m *model.Model, opts *exportOptions) error
+}
+
+// Options of the export command that are not used by all formats.
+type exportOptions struct {
+	svg tracks2d.SVGOptions
+}
+
+var exportFormats = []*exportFormat{
+	{name: "canvas", description: "the 2D track plan as JSON (tracks2d.Canvas)", write: exportCanvas},
+	{name: "svg", description: "the 2D track plan as SVG image", write: exportSVG},
+	{name: "switchboard", description: "the switchboard as JSON (switchboard.TrackDiagram)", write: exportSwitchboard},
+	{name: "bom-csv", description: "the bill of materials as CSV", write: exportBOMCSV},
+	{name: "bom-json", description: "the bill of materials as JSON (bom.BillOfMaterials)", write: exportBOMJSON},
+	{name: "grades", description: "the height and incline of all tracks as CSV", write: exportGrades},
+	{name: "blocks", description: "the blocks with their length, feedback contacts and occupancy detector as CSV", write: exportBlocks},
+}
+
+func exportCanvas(w io.Writer, m *model.Model, opts *exportOptions) error {
+	return writeJSON(w, renderCanvas(m))
+}
+
+func exportSVG(w io.Writer, m *model.Model, opts *exportOptions) error {
+	return tracks2d.WriteSVG(w, renderCanvas(m), &opts.svg)
+}
+
+// Renders the track plan and highlights unreachable tracks and reversing loops.
+func renderCanvas(m *model.Model) *tracks2d.Canvas {
+	c := tracks2d.Render(m)
+	r := analysis.Reach(m, m.Rules.MainLine)
+	c.Highlight("unreachable", r.Unreachable)
+	for _, loop := range r.ReversingLoops {
+		c.Highlight("reversing", loop)
+	}
+	return c
+}
+
+func exportSwitchboard(w io.Writer, m *model.Model, opts *exportOptions) error {
+	return writeJSON(w, switchboard.Render(m.Switchboards))
+}
+
+func exportBOMCSV(w io.Writer, m *model.Model, opts *exportOptions) error {
+	return bom.Render(m).WriteCSV(w)
+}
+
+func exportBOMJSON(w io.Writer, m *model.Model, opts *exportOptions) error {
+	return writeJSON(w, bom.Render(m))
+}
+
+func exportGrades(w io.Writer, m *model.Model, opts *exportOptions) error {
+	return analysis.WriteGradesCSV(w, analysis.Grades(m))
+}
+
+func exportBlocks(w io.Writer, m *model.Model, opts *exportOptions) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"block", "from", "to", "length", "contacts", "detector"})
+	for _, b := range m.Blocks {
+		var contacts []string
+		for _, c := range b.Contacts {
+			contacts = append(contacts, strconv.Itoa(c))
+		}
+		detector := ""
+		if b.Detector != 0 {
+			detector = strconv.Itoa(b.Detector)
+		}
+		cw.Write([]string{b.Name, b.From.Name(), b.To.Name(), strconv.FormatFloat(b.Length, 'f', 1, 64), strings.Join(contacts, " "), detector})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
+
+func runExport(args []string) int {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	formatName := fs.String("format", "canvas", "The output format")
+	output := fs.String("o", "", "The output file. Defaults to stdout")
+	name := fs.String("name", "", "The name of the layout. Defaults to the file name")
+	opts := &exportOptions{}
+	fs.Float64Var(&opts.svg.Scale, "scale", 1, "svg: Size of 1 mm of the layout in mm on paper, e.g. 0.1 for a scale of 1:10")
+	fs.BoolVar(&opts.svg.Dimensions, "dimensions", false, "svg: Draw dimension lines along the ground plates")
+	fs.Usage = func() {
+		fmt.Fprint(os.Stderr, "Usage: ferrovia export [flags] file.via\n\nFlags:\n")
+		fs.PrintDefaults()
+		fmt.Fprint(os.Stderr, "\nFormats:\n")
+		for _, f := range exportFormats {
+			fmt.Fprintf(os.Stderr, "  %-12v %v\n", f.name, f.description)
+		}
+	}
+	filename, ok := parseCommandLine(fs, args)
+	if !ok {
+		return 2
+	}
+
+	var format *exportFormat
+	for _, f := range exportFormats {
+		if f.name == *formatName {
+			format = f
+		}
+	}
+	if format == nil {
+		fmt.Fprintf(os.Stderr, "Unknown format %v\n", *formatName)
+		fs.Usage()
+		return 2
+	}
+
+	m, log, err := loadFile(filename, analysis.DefaultConfig())
+	if err != nil {
+		if log == nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+		}
+		return 1
+	}
+	log.Print()
+	m.Name = *name
+	if m.Name == "" {
+		m.Name = filename
+	}
+
+	w := io.Writer(os.Stdout)
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err.Error())
+			return 1
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := format.write(w, m, opts); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	return 0
+}
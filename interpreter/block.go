@@ -0,0 +1,139 @@
+package interpreter
+
+import (
+	"math"
+	"strconv"
+
+	"github.com/weistn/ferrovia/errlog"
+	"github.com/weistn/ferrovia/model"
+	"github.com/weistn/ferrovia/model/routing"
+	"github.com/weistn/ferrovia/parser"
+)
+
+// Implements IContext
+// Defines a block between two marks together with its feedback contacts, occupancy detector and signal.
+type BlockContext struct {
+	block *model.Block
+	// Location of the block name
+	location errlog.LocationRange
+	from     string
+	fromLoc  errlog.LocationRange
+	to       string
+	toLoc    errlog.LocationRange
+	funcs    map[string]*FuncValue
+}
+
+func NewBlockContext(name string, loc errlog.LocationRange) *BlockContext {
+	ctx := &BlockContext{block: &model.Block{Name: name}, location: loc, funcs: make(map[string]*FuncValue)}
+	ctx.funcs["from"] = &FuncValue{
+		Name: "from",
+		Func: func(b *Interpreter, c []IContext, loc errlog.LocationRange, args ...parser.IExpression) (*ExprValue, *errlog.Error) {
+			if len(args) != 1 {
+				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "1")
+			}
+			var err *errlog.Error
+			ctx.from, err = b.evalToString(c, args[0])
+			ctx.fromLoc = parser.ExpressionLocation(args[0])
+			return nil, err
+		},
+	}
+	ctx.funcs["to"] = &FuncValue{
+		Name: "to",
+		Func: func(b *Interpreter, c []IContext, loc errlog.LocationRange, args ...parser.IExpression) (*ExprValue, *errlog.Error) {
+			if len(args) != 1 {
+				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "1")
+			}
+			var err *errlog.Error
+			ctx.to, err = b.evalToString(c, args[0])
+			ctx.toLoc = parser.ExpressionLocation(args[0])
+			return nil, err
+		},
+	}
+	ctx.funcs["contact"] = &FuncValue{
+		Name: "contact",
+		Func: func(b *Interpreter, c []IContext, loc errlog.LocationRange, args ...parser.IExpression) (*ExprValue, *errlog.Error) {
+			if len(args) != 1 {
+				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "1")
+			}
+			address, err := b.evalToAddress(c, args[0])
+			if err != nil {
+				return nil, err
+			}
+			ctx.block.Contacts = append(ctx.block.Contacts, address)
+			return nil, nil
+		},
+	}
+	ctx.funcs["detector"] = &FuncValue{
+		Name: "detector",
+		Func: func(b *Interpreter, c []IContext, loc errlog.LocationRange, args ...parser.IExpression) (*ExprValue, *errlog.Error) {
+			if len(args) != 1 {
+				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "1")
+			}
+			var err *errlog.Error
+			ctx.block.Detector, err = b.evalToAddress(c, args[0])
+			return nil, err
+		},
+	}
+	ctx.funcs["signal"] = &FuncValue{
+		Name: "signal",
+		Func: func(b *Interpreter, c []IContext, loc errlog.LocationRange, args ...parser.IExpression) (*ExprValue, *errlog.Error) {
+			if len(args) != 1 {
+				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "1")
+			}
+			var err *errlog.Error
+			ctx.block.Signal, err = b.evalToAddress(c, args[0])
+			return nil, err
+		},
+	}
+	return ctx
+}
+
+func (c *BlockContext) Lookup(b *Interpreter, loc errlog.LocationRange, name string) (*ExprValue, *errlog.Error) {
+	if f, ok := c.funcs[name]; ok {
+		return &ExprValue{Type: funcType, FuncValue: f}, nil
+	}
+	return nil, nil
+}
+
+func (c *BlockContext) Process(b *Interpreter, loc errlog.LocationRange, value *ExprValue) *errlog.Error {
+	return b.errlog.LogError(errlog.ErrorIllegalInThisContext, loc)
+}
+
+// Lays out the block along the shortest route between its marks. All tracks must be known by now.
+func (c *BlockContext) Close(b *Interpreter) *errlog.Error {
+	if c.from == "" || c.to == "" {
+		return b.errlog.LogError(errlog.ErrorBlockIncomplete, c.location, c.block.Name)
+	}
+	if c.block.From = b.model.Tracks.GetMark(c.from); c.block.From == nil {
+		return b.errlog.LogError(errlog.ErrorUnknownMark, c.fromLoc, c.from)
+	}
+	if c.block.To = b.model.Tracks.GetMark(c.to); c.block.To == nil {
+		return b.errlog.LogError(errlog.ErrorUnknownMark, c.toLoc, c.to)
+	}
+	// Routes run between track connections only
+	if c.block.From.Connection == nil {
+		return b.errlog.LogError(errlog.ErrorBlockMarkInsideTrack, c.fromLoc, c.block.Name, c.from)
+	}
+	if c.block.To.Connection == nil {
+		return b.errlog.LogError(errlog.ErrorBlockMarkInsideTrack, c.toLoc, c.block.Name, c.to)
+	}
+	r := routing.Shortest(c.block.From, c.block.To)
+	if r == nil {
+		return b.errlog.LogError(errlog.ErrorBlockWithoutRoute, c.location, c.block.Name, c.from, c.to)
+	}
+	c.block.Tracks = r.Tracks
+	c.block.Length = r.Length
+	return nil
+}
+
+// Evaluates the address of a feedback contact or occupancy detector.
+func (b *Interpreter) evalToAddress(ctx []IContext, expr parser.IExpression) (int, *errlog.Error) {
+	f, err := b.evalToFloat(ctx, expr)
+	if err != nil {
+		return 0, err
+	}
+	if f < 1 || f != math.Trunc(f) || f > math.MaxInt32 {
+		return 0, b.errlog.LogError(errlog.ErrorIllegalAddress, parser.ExpressionLocation(expr), strconv.FormatFloat(f, 'f', -1, 64))
+	}
+	return int(f), nil
+}
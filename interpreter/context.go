@@ -1,8 +1,11 @@
 package interpreter
 
 import (
+	"fmt"
+
 	"github.com/weistn/ferrovia/errlog"
 	"github.com/weistn/ferrovia/model/tracks"
+	"github.com/weistn/ferrovia/parser"
 )
 
 type IContext interface {
@@ -31,11 +34,15 @@ func (ctx *GlobalContext) Lookup(b *Interpreter, loc errlog.LocationRange, name
 	if ok {
 		switch t := ident.(type) {
 		case *TracksContext:
+			// Named tracks can be used more than once, e.g. at the start and the end of a loop.
+			// Connecting any of their tracks twice is reported when the using context is closed.
 			return &ExprValue{Type: contextType, Context: t}, nil
+		case *TracksTemplate:
+			return &ExprValue{Type: funcType, FuncValue: &t.fn}, nil
 		case *LayerContext:
 			return &ExprValue{Type: contextType, Context: t}, nil
 		default:
-			panic("Ooooops")
+			return nil, b.errlog.LogError(errlog.ErrorInternal, loc, fmt.Sprintf("unknown identifier %T", t))
 		}
 	}
 	return nil, nil
@@ -50,14 +57,21 @@ func (ctx *GlobalContext) Close(b *Interpreter) *errlog.Error {
 }
 
 func (ctx *GlobalContext) RegisterTracks(b *Interpreter, loc errlog.LocationRange, name string) (*TracksContext, *errlog.Error) {
-	ident, ok := ctx.identifiers[name]
-	if ok {
-		if t, ok := ident.(*TracksContext); ok {
-			return t, nil
-		}
+	if _, ok := ctx.identifiers[name]; ok {
 		return nil, b.errlog.LogError(errlog.ErrorDuplicateIdentifier, loc, name)
 	}
 	t := NewTracksContext(b.model.Tracks.Layers[""])
+	t.group = name
+	ctx.identifiers[name] = t
+	return t, nil
+}
+
+func (ctx *GlobalContext) RegisterTemplate(b *Interpreter, loc errlog.LocationRange, ast *parser.Tracks) (*TracksTemplate, *errlog.Error) {
+	name := ast.Name.StringValue
+	if _, ok := ctx.identifiers[name]; ok {
+		return nil, b.errlog.LogError(errlog.ErrorDuplicateIdentifier, loc, name)
+	}
+	t := NewTracksTemplate(ast)
 	ctx.identifiers[name] = t
 	return t, nil
 }
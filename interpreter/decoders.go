@@ -0,0 +1,80 @@
+package interpreter
+
+import (
+	"strconv"
+
+	"github.com/weistn/ferrovia/errlog"
+	"github.com/weistn/ferrovia/model"
+	"github.com/weistn/ferrovia/parser"
+)
+
+// Implements IContext
+// Assigns the addresses of the accessory decoders which operate turnouts.
+type DecodersContext struct {
+	model *model.Model
+	funcs map[string]*FuncValue
+}
+
+func NewDecodersContext(m *model.Model) *DecodersContext {
+	ctx := &DecodersContext{model: m, funcs: make(map[string]*FuncValue)}
+	ctx.funcs["turnout"] = &FuncValue{
+		Name: "turnout",
+		Func: func(b *Interpreter, c []IContext, loc errlog.LocationRange, args ...parser.IExpression) (*ExprValue, *errlog.Error) {
+			if len(args) != 2 && len(args) != 3 {
+				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "2 or 3")
+			}
+			name, err := b.evalToString(c, args[0])
+			if err != nil {
+				return nil, err
+			}
+			mark := ctx.model.Tracks.GetMark(name)
+			if mark == nil {
+				return nil, b.errlog.LogError(errlog.ErrorUnknownMark, parser.ExpressionLocation(args[0]), name)
+			}
+			t := mark.Turnout()
+			if t == nil {
+				return nil, b.errlog.LogError(errlog.ErrorSwitchWithoutTurnout, parser.ExpressionLocation(args[0]), name)
+			}
+			// The two outputs of a decoder select two options, hence turnouts with more options require two decoders
+			n := len(t.Geometry.TurnoutOptions)
+			if n > 4 {
+				return nil, b.errlog.LogError(errlog.ErrorDecoderTooManyOptions, parser.ExpressionLocation(args[0]), name, strconv.Itoa(n))
+			}
+			if n <= 2 && len(args) != 2 {
+				return nil, b.errlog.LogError(errlog.ErrorDecoderAddressCount, loc, name, strconv.Itoa(n), "one decoder address")
+			}
+			if n > 2 && len(args) != 3 {
+				return nil, b.errlog.LogError(errlog.ErrorDecoderAddressCount, loc, name, strconv.Itoa(n), "two decoder addresses")
+			}
+			var addresses []int
+			for _, arg := range args[1:] {
+				address, err := b.evalToAddress(c, arg)
+				if err != nil {
+					return nil, err
+				}
+				addresses = append(addresses, address)
+			}
+			if ctx.model.TurnoutAddresses == nil {
+				ctx.model.TurnoutAddresses = make(map[string][]int)
+			}
+			ctx.model.TurnoutAddresses[name] = addresses
+			return nil, nil
+		},
+	}
+	return ctx
+}
+
+func (c *DecodersContext) Lookup(b *Interpreter, loc errlog.LocationRange, name string) (*ExprValue, *errlog.Error) {
+	if f, ok := c.funcs[name]; ok {
+		return &ExprValue{Type: funcType, FuncValue: f}, nil
+	}
+	return nil, nil
+}
+
+func (c *DecodersContext) Process(b *Interpreter, loc errlog.LocationRange, value *ExprValue) *errlog.Error {
+	return b.errlog.LogError(errlog.ErrorIllegalInThisContext, loc)
+}
+
+func (c *DecodersContext) Close(b *Interpreter) *errlog.Error {
+	return nil
+}
@@ -58,13 +58,38 @@ func (e *ExprValue) Equal(p *ExprValue, loc errlog.LocationRange) (*ExprValue, *
 			result.NumberValue = 0
 		}
 	case vectorType:
-		panic("TODO")
+		equal, err := e.equalVector(p, loc)
+		if err != nil {
+			return nil, err
+		}
+		if equal {
+			result.NumberValue = 1
+		} else {
+			result.NumberValue = 0
+		}
 	default:
-		panic("TODO")
+		return nil, errlog.NewError(errlog.ErrorTypeMismtach, loc)
 	}
 	return result, nil
 }
 
+// Vectors are equal if they have the same length and all their elements are equal.
+func (e *ExprValue) equalVector(p *ExprValue, loc errlog.LocationRange) (bool, *errlog.Error) {
+	if len(e.VectorValue) != len(p.VectorValue) {
+		return false, nil
+	}
+	for i, v := range e.VectorValue {
+		r, err := v.Equal(p.VectorValue[i], loc)
+		if err != nil {
+			return false, err
+		}
+		if r.NumberValue == 0 {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
 func (e *ExprValue) NotEqual(p *ExprValue, loc errlog.LocationRange) (*ExprValue, *errlog.Error) {
 	if e.Type != p.Type {
 		return nil, errlog.NewError(errlog.ErrorTypeMismtach, loc)
@@ -84,9 +109,17 @@ func (e *ExprValue) NotEqual(p *ExprValue, loc errlog.LocationRange) (*ExprValue
 			result.NumberValue = 0
 		}
 	case vectorType:
-		panic("TODO")
+		equal, err := e.equalVector(p, loc)
+		if err != nil {
+			return nil, err
+		}
+		if !equal {
+			result.NumberValue = 1
+		} else {
+			result.NumberValue = 0
+		}
 	default:
-		panic("TODO")
+		return nil, errlog.NewError(errlog.ErrorTypeMismtach, loc)
 	}
 	return result, nil
 }
@@ -112,7 +145,7 @@ func (e *ExprValue) LessOrEqual(p *ExprValue, loc errlog.LocationRange) (*ExprVa
 	case vectorType:
 		return nil, errlog.NewError(errlog.ErrorTypeMismtach, loc)
 	default:
-		panic("TODO")
+		return nil, errlog.NewError(errlog.ErrorTypeMismtach, loc)
 	}
 	return result, nil
 }
@@ -138,7 +171,7 @@ func (e *ExprValue) GreaterOrEqual(p *ExprValue, loc errlog.LocationRange) (*Exp
 	case vectorType:
 		return nil, errlog.NewError(errlog.ErrorTypeMismtach, loc)
 	default:
-		panic("TODO")
+		return nil, errlog.NewError(errlog.ErrorTypeMismtach, loc)
 	}
 	return result, nil
 }
@@ -164,7 +197,7 @@ func (e *ExprValue) Less(p *ExprValue, loc errlog.LocationRange) (*ExprValue, *e
 	case vectorType:
 		return nil, errlog.NewError(errlog.ErrorTypeMismtach, loc)
 	default:
-		panic("TODO")
+		return nil, errlog.NewError(errlog.ErrorTypeMismtach, loc)
 	}
 	return result, nil
 }
@@ -190,7 +223,7 @@ func (e *ExprValue) Greater(p *ExprValue, loc errlog.LocationRange) (*ExprValue,
 	case vectorType:
 		return nil, errlog.NewError(errlog.ErrorTypeMismtach, loc)
 	default:
-		panic("TODO")
+		return nil, errlog.NewError(errlog.ErrorTypeMismtach, loc)
 	}
 	return result, nil
 }
@@ -208,7 +241,7 @@ func (e *ExprValue) Plus(p *ExprValue, loc errlog.LocationRange) (*ExprValue, *e
 	case vectorType:
 		return nil, errlog.NewError(errlog.ErrorTypeMismtach, loc)
 	default:
-		panic("TODO")
+		return nil, errlog.NewError(errlog.ErrorTypeMismtach, loc)
 	}
 	return result, nil
 }
@@ -226,7 +259,7 @@ func (e *ExprValue) Minus(p *ExprValue, loc errlog.LocationRange) (*ExprValue, *
 	case vectorType:
 		return nil, errlog.NewError(errlog.ErrorTypeMismtach, loc)
 	default:
-		panic("TODO")
+		return nil, errlog.NewError(errlog.ErrorTypeMismtach, loc)
 	}
 	return result, nil
 }
@@ -244,7 +277,7 @@ func (e *ExprValue) Mul(p *ExprValue, loc errlog.LocationRange) (*ExprValue, *er
 	case vectorType:
 		return nil, errlog.NewError(errlog.ErrorTypeMismtach, loc)
 	default:
-		panic("TODO")
+		return nil, errlog.NewError(errlog.ErrorTypeMismtach, loc)
 	}
 	return result, nil
 }
@@ -262,7 +295,7 @@ func (e *ExprValue) Div(p *ExprValue, loc errlog.LocationRange) (*ExprValue, *er
 	case vectorType:
 		return nil, errlog.NewError(errlog.ErrorTypeMismtach, loc)
 	default:
-		panic("TODO")
+		return nil, errlog.NewError(errlog.ErrorTypeMismtach, loc)
 	}
 	return result, nil
 }
@@ -0,0 +1,145 @@
+package interpreter
+
+import (
+	"strconv"
+
+	"github.com/weistn/ferrovia/errlog"
+	"github.com/weistn/ferrovia/model/tracks"
+	"github.com/weistn/ferrovia/parser"
+)
+
+// Implements IContext
+// Defines a piece of track. Simple pieces are defined by one of straight, curve or turnout.
+// All other pieces are composed of lines, arcs and connections like the pieces in a catalog file.
+type GeometryContext struct {
+	piece *tracks.CatalogPiece
+	funcs map[string]*FuncValue
+}
+
+func NewGeometryContext(name string) *GeometryContext {
+	// Pieces defined by a layout are not sold by any vendor
+	ctx := &GeometryContext{piece: &tracks.CatalogPiece{Name: name, Custom: true}, funcs: make(map[string]*FuncValue)}
+	ctx.define("straight", func(b *Interpreter, loc errlog.LocationRange, args []float64) *errlog.Error {
+		ctx.piece.Straight = &tracks.CatalogStraight{Length: args[0]}
+		return nil
+	}, 1)
+	ctx.define("curve", func(b *Interpreter, loc errlog.LocationRange, args []float64) *errlog.Error {
+		ctx.piece.Curve = &tracks.CatalogCurve{Radius: args[0], Angle: args[1]}
+		return nil
+	}, 2)
+	ctx.define("line", func(b *Interpreter, loc errlog.LocationRange, args []float64) *errlog.Error {
+		p := &tracks.CatalogPath{Length: args[0]}
+		if len(args) == 4 {
+			p.Anchor = tracks.CatalogPoint{X: args[1], Y: args[2], Angle: args[3]}
+		}
+		ctx.geometry().Paths = append(ctx.geometry().Paths, p)
+		return nil
+	}, 1, 4)
+	ctx.define("arc", func(b *Interpreter, loc errlog.LocationRange, args []float64) *errlog.Error {
+		p := &tracks.CatalogPath{Radius: args[0], Angle: args[1]}
+		if len(args) == 5 {
+			p.Anchor = tracks.CatalogPoint{X: args[2], Y: args[3], Angle: args[4]}
+		}
+		ctx.geometry().Paths = append(ctx.geometry().Paths, p)
+		return nil
+	}, 2, 5)
+	ctx.define("connection", func(b *Interpreter, loc errlog.LocationRange, args []float64) *errlog.Error {
+		ctx.geometry().Connections = append(ctx.geometry().Connections, tracks.CatalogPoint{X: args[0], Y: args[1], Angle: args[2]})
+		return nil
+	}, 3)
+	ctx.define("incoming", func(b *Interpreter, loc errlog.LocationRange, args []float64) *errlog.Error {
+		if args[0] != float64(int(args[0])) {
+			return b.errlog.LogError(errlog.ErrorTypeMismtach, loc)
+		}
+		ctx.geometry().Incoming = int(args[0])
+		return nil
+	}, 1)
+	ctx.define("option", func(b *Interpreter, loc errlog.LocationRange, args []float64) *errlog.Error {
+		if args[0] != float64(int(args[0])) || args[1] != float64(int(args[1])) {
+			return b.errlog.LogError(errlog.ErrorTypeMismtach, loc)
+		}
+		ctx.geometry().Options = append(ctx.geometry().Options, [2]int{int(args[0]), int(args[1])})
+		return nil
+	}, 2)
+	ctx.funcs["turnout"] = &FuncValue{
+		Name: "turnout",
+		Func: func(b *Interpreter, c []IContext, loc errlog.LocationRange, args ...parser.IExpression) (*ExprValue, *errlog.Error) {
+			if len(args) != 4 {
+				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "4")
+			}
+			values, err := b.evalToFloats(c, args[:3])
+			if err != nil {
+				return nil, err
+			}
+			branch, err := b.evalToString(c, args[3])
+			if err != nil {
+				return nil, err
+			}
+			ctx.piece.Turnout = &tracks.CatalogTurnout{Length: values[0], Radius: values[1], Angle: values[2], Branch: branch}
+			return nil, nil
+		},
+	}
+	ctx.funcs["reversed"] = &FuncValue{
+		Name: "reversed",
+		Func: func(b *Interpreter, c []IContext, loc errlog.LocationRange, args ...parser.IExpression) (*ExprValue, *errlog.Error) {
+			if len(args) != 1 {
+				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "1")
+			}
+			name, err := b.evalToString(c, args[0])
+			if err != nil {
+				return nil, err
+			}
+			ctx.piece.Reversed = name
+			return nil, nil
+		},
+	}
+	return ctx
+}
+
+// Defines a function which accepts numbers only.
+// The counts list the allowed number of arguments.
+func (ctx *GeometryContext) define(name string, fn func(b *Interpreter, loc errlog.LocationRange, args []float64) *errlog.Error, counts ...int) {
+	ctx.funcs[name] = &FuncValue{
+		Name: name,
+		Func: func(b *Interpreter, c []IContext, loc errlog.LocationRange, args ...parser.IExpression) (*ExprValue, *errlog.Error) {
+			ok := false
+			for _, count := range counts {
+				ok = ok || len(args) == count
+			}
+			if !ok {
+				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, strconv.Itoa(counts[len(counts)-1]))
+			}
+			values, err := b.evalToFloats(c, args)
+			if err != nil {
+				return nil, err
+			}
+			return nil, fn(b, loc, values)
+		},
+	}
+}
+
+// Returns the geometry of a composite piece.
+func (ctx *GeometryContext) geometry() *tracks.CatalogGeometry {
+	if ctx.piece.Geometry == nil {
+		ctx.piece.Geometry = &tracks.CatalogGeometry{Incoming: 1}
+	}
+	return ctx.piece.Geometry
+}
+
+func (c *GeometryContext) Lookup(b *Interpreter, loc errlog.LocationRange, name string) (*ExprValue, *errlog.Error) {
+	if f, ok := c.funcs[name]; ok {
+		return &ExprValue{Type: funcType, FuncValue: f}, nil
+	}
+	return nil, nil
+}
+
+func (c *GeometryContext) Process(b *Interpreter, loc errlog.LocationRange, value *ExprValue) *errlog.Error {
+	return b.errlog.LogError(errlog.ErrorIllegalInThisContext, loc)
+}
+
+func (c *GeometryContext) Close(b *Interpreter) *errlog.Error {
+	if g := c.piece.Geometry; g != nil {
+		g.Outgoing = len(g.Connections) - g.Incoming
+	}
+	return nil
+}
@@ -25,10 +25,10 @@ func NewGroundContext(ground *model.GroundPlate) *GroundContext {
 				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "1")
 			}
 			arg, err := b.evalExpression(c, args[0])
-			if arg != nil {
+			if err != nil {
 				return nil, err
 			}
-			ctx.Ground.Top, err = b.ToFloat(arg, loc)
+			ctx.Ground.Top, err = b.ToFloat(arg, parser.ExpressionLocation(args[0]))
 			return nil, err
 		},
 	}
@@ -39,10 +39,10 @@ func NewGroundContext(ground *model.GroundPlate) *GroundContext {
 				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "1")
 			}
 			arg, err := b.evalExpression(c, args[0])
-			if arg != nil {
+			if err != nil {
 				return nil, err
 			}
-			ctx.Ground.Left, err = b.ToFloat(arg, loc)
+			ctx.Ground.Left, err = b.ToFloat(arg, parser.ExpressionLocation(args[0]))
 			return nil, err
 		},
 	}
@@ -53,10 +53,10 @@ func NewGroundContext(ground *model.GroundPlate) *GroundContext {
 				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "1")
 			}
 			arg, err := b.evalExpression(c, args[0])
-			if arg != nil {
+			if err != nil {
 				return nil, err
 			}
-			ctx.Ground.Width, err = b.ToFloat(arg, loc)
+			ctx.Ground.Width, err = b.ToFloat(arg, parser.ExpressionLocation(args[0]))
 			return nil, err
 		},
 	}
@@ -67,10 +67,10 @@ func NewGroundContext(ground *model.GroundPlate) *GroundContext {
 				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "1")
 			}
 			arg, err := b.evalExpression(c, args[0])
-			if arg != nil {
+			if err != nil {
 				return nil, err
 			}
-			ctx.Ground.Height, err = b.ToFloat(arg, loc)
+			ctx.Ground.Height, err = b.ToFloat(arg, parser.ExpressionLocation(args[0]))
 			return nil, err
 		},
 	}
@@ -78,25 +78,26 @@ func NewGroundContext(ground *model.GroundPlate) *GroundContext {
 		Name: "polygon",
 		Func: func(b *Interpreter, c []IContext, loc errlog.LocationRange, args ...parser.IExpression) (*ExprValue, *errlog.Error) {
 			if len(args) < 3 {
-				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "1")
+				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "3")
 			}
 			for _, argexpr := range args {
 				arg, err := b.evalExpression(c, argexpr)
-				if arg != nil {
+				if err != nil {
 					return nil, err
 				}
-				vector, err := b.ToVector(arg, loc)
+				argloc := parser.ExpressionLocation(argexpr)
+				vector, err := b.ToVector(arg, argloc)
 				if err != nil {
 					return nil, err
 				}
 				if len(vector) != 2 {
-					return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "1")
+					return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, argloc, "2")
 				}
-				x, err := b.ToFloat(vector[0], loc)
+				x, err := b.ToFloat(vector[0], argloc)
 				if err != nil {
 					return nil, err
 				}
-				y, err := b.ToFloat(vector[1], loc)
+				y, err := b.ToFloat(vector[1], argloc)
 				if err != nil {
 					return nil, err
 				}
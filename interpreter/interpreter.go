@@ -2,6 +2,8 @@ package interpreter
 
 import (
 	"fmt"
+	"math"
+	"strconv"
 	"strings"
 
 	"github.com/weistn/ferrovia/errlog"
@@ -11,11 +13,20 @@ import (
 )
 
 type Interpreter struct {
-	errlog           *errlog.ErrorLog
-	ast              *parser.File
-	model            *model.Model
-	tracksWithAnchor []*tracks.Track
-	ctx              *GlobalContext
+	errlog *errlog.ErrorLog
+	ast    *parser.File
+	model  *model.Model
+	// Connections which have been positioned by an anchor.
+	anchors []*tracks.TrackConnection
+	ramps   []*ramp
+	ctx     *GlobalContext
+	// The directive which selected the track system or nil.
+	system *parser.System
+	// The pieces defined by geometry directives or nil.
+	geometries *tracks.Catalog
+	flexes     []*flex
+	// Location of the mark named by the mainLine rule.
+	mainLine errlog.LocationRange
 }
 
 func NewInterpreter(errlog *errlog.ErrorLog) *Interpreter {
@@ -24,8 +35,16 @@ func NewInterpreter(errlog *errlog.ErrorLog) *Interpreter {
 	return &Interpreter{errlog: errlog, model: m, ctx: NewGlobalContext()}
 }
 
-func (b *Interpreter) ProcessStatics(ast *parser.File) *model.Model {
+func (b *Interpreter) ProcessStatics(ast *parser.File) (m *model.Model) {
 	b.ast = ast
+	// As a last resort, inconsistencies which have not been anticipated are reported instead of crashing.
+	// The remaining passes are skipped in this case.
+	defer func() {
+		if r := recover(); r != nil {
+			b.errlog.LogError(errlog.ErrorInternal, errlog.LocationRange{From: ast.Location, To: ast.Location}, fmt.Sprint(r))
+			m = b.model
+		}
+	}()
 
 	// Determine all identifiers mentioned in the switchboards
 	for _, s := range ast.Statements {
@@ -35,18 +54,28 @@ func (b *Interpreter) ProcessStatics(ast *parser.File) *model.Model {
 		switch t := s.(type) {
 		case *parser.GroundPlate:
 			// Do nothing by intention
+		case *parser.Geometry:
+			// Do nothing by intention
+		case *parser.Block:
+			// Do nothing by intention
+		case *parser.Decoders:
+			// Do nothing by intention
+		case *parser.Rules:
+			// Do nothing by intention
 		case *parser.Layer:
 			// Do nothing by intention
 		case *parser.Switchboard:
 			b.processSwitchboard(t)
+		case *parser.System:
+			b.processSystem(t)
 		case *parser.Tracks:
 			// Do nothing by intention
 		default:
-			panic("Ooooops")
+			b.errlog.LogError(errlog.ErrorInternal, errlog.LocationRange{From: ast.Location, To: ast.Location}, fmt.Sprintf("unknown statement %T", s))
 		}
 	}
 
-	// Compute all ground plates and layers
+	// Compute all ground plates, layers and geometries and register all tracks templates
 	for _, s := range ast.Statements {
 		if s == nil {
 			break
@@ -54,14 +83,26 @@ func (b *Interpreter) ProcessStatics(ast *parser.File) *model.Model {
 		switch t := s.(type) {
 		case *parser.GroundPlate:
 			b.processGround(t)
+		case *parser.Geometry:
+			b.processGeometry(t)
+		case *parser.Block:
+			// Do nothing by intention
+		case *parser.Decoders:
+			// Do nothing by intention
+		case *parser.Rules:
+			// Do nothing by intention
 		case *parser.Layer:
 			b.processLayer(t)
 		case *parser.Switchboard:
 			// Do nothing by intention
-		case *parser.Tracks:
+		case *parser.System:
 			// Do nothing by intention
+		case *parser.Tracks:
+			if t.Name != nil && t.Parameters != nil {
+				b.ctx.RegisterTemplate(b, t.Name.Location, t)
+			}
 		default:
-			panic("Ooooops")
+			// Reported by the first pass
 		}
 	}
 
@@ -73,19 +114,27 @@ func (b *Interpreter) ProcessStatics(ast *parser.File) *model.Model {
 		switch t := s.(type) {
 		case *parser.GroundPlate:
 			// Do nothing by intention
+		case *parser.Geometry:
+			// Do nothing by intention
+		case *parser.Block:
+			// Do nothing by intention
+		case *parser.Decoders:
+			// Do nothing by intention
+		case *parser.Rules:
+			// The layers are known by now
+			b.processRules(t)
 		case *parser.Layer:
 			// Do nothing by intention
 		case *parser.Switchboard:
 			// Do nothing by intention
+		case *parser.System:
+			// Do nothing by intention
 		case *parser.Tracks:
-			if t.Name != nil {
-				err := b.processTracks(t)
-				if err != nil {
-					return b.model
-				}
+			if t.Name != nil && t.Parameters == nil {
+				b.processTracks(t)
 			}
 		default:
-			panic("Ooooops")
+			// Reported by the first pass
 		}
 	}
 
@@ -97,25 +146,60 @@ func (b *Interpreter) ProcessStatics(ast *parser.File) *model.Model {
 		switch t := s.(type) {
 		case *parser.GroundPlate:
 			// Do nothing by intention
+		case *parser.Geometry:
+			// Do nothing by intention
+		case *parser.Block:
+			// Do nothing by intention
+		case *parser.Decoders:
+			// Do nothing by intention
+		case *parser.Rules:
+			// Do nothing by intention
 		case *parser.Layer:
 			// Do nothing by intention
 		case *parser.Switchboard:
 			// Do nothing by intention
+		case *parser.System:
+			// Do nothing by intention
 		case *parser.Tracks:
 			if t.Name == nil {
-				err := b.processTracks(t)
-				if err != nil {
-					return b.model
-				}
+				b.processTracks(t)
 			}
 		default:
-			panic("Ooooops")
+			// Reported by the first pass
 		}
 	}
 
 	// Determine the location of all tracks which are directly or indirectly anchored
-	for _, t := range b.tracksWithAnchor {
-		b.computeLocationFromAnchor(t)
+	for _, con := range b.anchors {
+		b.computeLocationFromAnchor(con)
+	}
+	// Flexible tracks join tracks which have been located independently
+	for _, f := range b.flexes {
+		b.computeFlex(f)
+	}
+	for _, r := range b.ramps {
+		if !r.resolved {
+			b.errlog.LogError(errlog.ErrorHeightUnreachable, r.location, strconv.FormatFloat(r.height, 'f', -1, 64))
+		}
+	}
+
+	// Blocks lie between marks, which are known by now
+	for _, s := range ast.Statements {
+		if t, ok := s.(*parser.Block); ok {
+			b.processBlock(t)
+		}
+	}
+	b.bindBlocks()
+	for _, s := range ast.Statements {
+		if t, ok := s.(*parser.Decoders); ok {
+			b.processDecoders(t)
+		}
+	}
+
+	// Switches operate turnouts, which are known by now
+	b.bindSwitches()
+	if name := b.model.Rules.MainLine; name != "" && b.model.Tracks.GetMark(name) == nil {
+		b.errlog.LogError(errlog.ErrorUnknownMark, b.mainLine, name)
 	}
 
 	// All tracks should be located by now
@@ -144,6 +228,68 @@ func (b *Interpreter) processGround(ast *parser.GroundPlate) {
 	}
 }
 
+func (b *Interpreter) processGeometry(ast *parser.Geometry) {
+	ctx := NewGeometryContext(ast.Name.StringValue)
+	err := b.processStatements([]IContext{b.ctx, ctx}, ast.Expressions)
+	if err != nil {
+		return
+	}
+	if err = ctx.Close(b); err != nil {
+		return
+	}
+	// Geometries are only known to this layout, hence the catalog of the track system is not modified
+	if b.geometries == nil {
+		b.geometries = tracks.NewCatalog(b.model.Tracks.Catalog.Name, b.model.Tracks.Catalog)
+		b.model.Tracks.Catalog = b.geometries
+	}
+	if err := b.geometries.RegisterPiece(ctx.piece); err != nil {
+		b.errlog.LogError(errlog.ErrorInvalidGeometry, ast.Name.Location, ast.Name.StringValue, err.Error())
+	}
+}
+
+func (b *Interpreter) processBlock(ast *parser.Block) {
+	name := ast.Name.StringValue
+	if b.model.Block(name) != nil {
+		b.errlog.LogError(errlog.ErrorDuplicateIdentifier, ast.Name.Location, name)
+		return
+	}
+	ctx := NewBlockContext(name, ast.Name.Location)
+	if err := b.processStatements([]IContext{b.ctx, ctx}, ast.Expressions); err != nil {
+		return
+	}
+	if err := ctx.Close(b); err != nil {
+		return
+	}
+	// A track belongs to at most one block, otherwise a train would occupy two blocks at once
+	for _, other := range b.model.Blocks {
+		for _, t := range other.Tracks {
+			for _, t2 := range ctx.block.Tracks {
+				if t == t2 {
+					b.errlog.LogError(errlog.ErrorBlocksOverlap, ast.Name.Location, other.Name, name, t.Geometry.Name).AddRelated(t.SourceLocation)
+					return
+				}
+			}
+		}
+	}
+	b.model.Blocks = append(b.model.Blocks, ctx.block)
+}
+
+func (b *Interpreter) processDecoders(ast *parser.Decoders) {
+	ctx := NewDecodersContext(b.model)
+	if err := b.processStatements([]IContext{b.ctx, ctx}, ast.Expressions); err != nil {
+		return
+	}
+	ctx.Close(b)
+}
+
+func (b *Interpreter) processRules(ast *parser.Rules) {
+	ctx := NewRulesContext(&b.model.Rules)
+	if err := b.processStatements([]IContext{b.ctx, ctx}, ast.Expressions); err != nil {
+		return
+	}
+	ctx.Close(b)
+}
+
 func (b *Interpreter) processSwitchboard(ast *parser.Switchboard) {
 	lines := strings.Split(ast.RawText, "\n")
 	sb := processASCIIStructure(lines, ast.LocationText, b.errlog)
@@ -153,7 +299,7 @@ func (b *Interpreter) processSwitchboard(ast *parser.Switchboard) {
 }
 
 func (b *Interpreter) processLayer(ast *parser.Layer) {
-	ctx, err := b.ctx.RegisterLayer(b, errlog.LocationRange{}, ast.Name.StringValue)
+	ctx, err := b.ctx.RegisterLayer(b, ast.Name.Location, ast.Name.StringValue)
 	if err != nil {
 		return
 	}
@@ -172,20 +318,20 @@ func (b *Interpreter) processTracks(ast *parser.Tracks) *errlog.Error {
 	var err *errlog.Error
 	if ast.Name != nil {
 		// Named tracks
-		ctx, err = b.ctx.RegisterTracks(b, ast.Location, ast.Name.StringValue)
+		ctx, err = b.ctx.RegisterTracks(b, ast.Name.Location, ast.Name.StringValue)
 		if err != nil {
 			return err
 		}
-		// TODO: Do not allow defining the same named tracks twice.
 	} else {
 		// Anonymous tracks
 		ctx = NewTracksContext(b.model.Tracks.Layers[""])
 	}
 	err = b.processStatements([]IContext{b.ctx, ctx}, ast.Expressions)
-	if err != nil {
-		return err
+	// Connect the tracks even in case of errors to avoid subsequent errors
+	if err2 := ctx.Close(b); err == nil {
+		err = err2
 	}
-	return ctx.Close(b)
+	return err
 }
 
 /*
@@ -297,10 +443,30 @@ func (b *Interpreter) processAnchor(exp *parser.Expression, con *tracks.TrackCon
 }
 */
 
-func (b *Interpreter) computeLocationFromAnchor(track *tracks.Track) {
+func (b *Interpreter) processSystem(ast *parser.System) {
+	if b.system != nil {
+		b.errlog.LogError(errlog.ErrorDuplicateTrackSystem, ast.Location)
+		return
+	}
+	b.system = ast
+	c := tracks.GetCatalog(ast.Name.StringValue)
+	if c == nil {
+		b.errlog.LogError(errlog.ErrorUnknownTrackSystem, ast.Name.Location, ast.Name.StringValue, strings.Join(tracks.CatalogNames(), ", "))
+		return
+	}
+	b.model.Tracks.Catalog = c
+}
+
+func (b *Interpreter) computeLocationFromAnchor(con *tracks.TrackConnection) {
+	track := con.Track
 	if track.Location == nil {
 		panic("Track has no anchor")
 	}
+	if r := b.rampAt(con); r != nil {
+		pos, _ := track.Location.Connection(track.ConnectionIndex(con), track.Geometry)
+		r.resolve(pos[2])
+		track.UpdateIncline(con)
+	}
 	tracks.NewEpoch()
 	track.Tag()
 	// println(track.Geometry.Name, track.Id, track.Location.Center[0], track.Location.Center[1], track.Location.Rotation)
@@ -311,13 +477,16 @@ func (b *Interpreter) computeLocationOfConnectedTracks(track *tracks.Track) {
 	for i := 0; i < track.ConnectionCount(); i++ {
 		c := track.Connection(i)
 		c2 := c.Opposite
-		if c2 == nil || c2.Track.IsTagged() {
+		if c2 == nil || c2.Track.IsTagged() || b.isFlex(c2.Track) {
 			continue
 		}
 		if c2.Track.Location != nil {
 			b.errlog.LogError(errlog.ErrorTrackPositionedTwice, c2.Track.SourceLocation)
 		}
 		cpos, cangle := track.Location.Connection(i, track.Geometry)
+		if r := b.rampAt(c2); r != nil {
+			r.resolve(cpos[2])
+		}
 		// println("    con at ", cpos[0], cpos[1], cangle, i)
 		l := tracks.NewTrackLocation(c2, cpos, cangle)
 		// println(c2.Track.Geometry.Name, c2.Track.Id, l.Center[0], l.Center[1], l.Rotation)
@@ -327,43 +496,102 @@ func (b *Interpreter) computeLocationOfConnectedTracks(track *tracks.Track) {
 	}
 }
 
+func (b *Interpreter) isFlex(track *tracks.Track) bool {
+	for _, f := range b.flexes {
+		if f.track == track {
+			return true
+		}
+	}
+	return false
+}
+
+// Computes the shape and location of a flexible track from the location of the tracks at both ends.
+func (b *Interpreter) computeFlex(f *flex) {
+	var pos [2]tracks.Vec3
+	var angle [2]float64
+	for i := 0; i < 2; i++ {
+		c := f.track.Connection(i).Opposite
+		if c == nil || c.Track.Location == nil || b.isFlex(c.Track) {
+			b.errlog.LogError(errlog.ErrorFlexUnconnected, f.track.SourceLocation)
+			return
+		}
+		pos[i], angle[i] = c.Track.Location.Connection(c.Track.ConnectionIndex(c), c.Track.Geometry)
+	}
+	if f.track.Location != nil {
+		b.errlog.LogError(errlog.ErrorTrackPositionedTwice, f.track.SourceLocation)
+		return
+	}
+	// The flex track heads in the direction of the track at its start and against the direction of the track at its end
+	g, ok := tracks.NewFlexGeometry(tracks.Vec2{pos[0][0], pos[0][1]}, angle[0], tracks.Vec2{pos[1][0], pos[1][1]}, angle[1]+180, f.radius)
+	if !ok {
+		b.errlog.LogError(errlog.ErrorFlexTooTight, f.track.SourceLocation, strconv.FormatFloat(f.radius, 'f', -1, 64))
+		return
+	}
+	if g.Length() < 0.1 {
+		b.errlog.LogError(errlog.ErrorFlexNotNeeded, f.track.SourceLocation)
+		return
+	}
+	f.track.Geometry = g
+	f.track.Incline = (pos[1][2] - pos[0][2]) / g.Length() * 100
+	f.track.SetLocation(tracks.NewTrackLocation(f.track.Connection(0), pos[0], angle[0]))
+}
+
 // The error returned (if any) is already logged. It just indicates that something went wrong
+// Statements following a faulty statement are processed nevertheless to report as many errors as possible.
+// In this case the first error is returned.
 func (b *Interpreter) processStatements(ctx []IContext, ast []parser.IExpression) *errlog.Error {
+	var result *errlog.Error
 	for _, exp := range ast {
-		result, err := b.evalExpression(ctx, exp)
-		if err != nil {
-			return err
-		}
-		if result != nil {
-			result, err = b.expandFunc(ctx, result, errlog.LocationRange{})
-			if err != nil {
-				return err
-			}
-		}
-		if result != nil {
-			err = ctx[len(ctx)-1].Process(b, errlog.LocationRange{}, result)
-			if err != nil {
-				return err
-			}
+		if err := b.processStatement(ctx, exp); err != nil && result == nil {
+			result = err
 		}
 	}
-	return nil
+	return result
+}
+
+func (b *Interpreter) processStatement(ctx []IContext, exp parser.IExpression) *errlog.Error {
+	loc := parser.ExpressionLocation(exp)
+	result, err := b.evalExpression(ctx, exp)
+	// A faulty context is processed nevertheless, because its tracks must be connected
+	if result == nil || (err != nil && result.Type != contextType) {
+		return err
+	}
+	result, err2 := b.expandFunc(ctx, result, loc)
+	if err2 != nil {
+		return err2
+	}
+	if result == nil {
+		return err
+	}
+	if err2 = ctx[len(ctx)-1].Process(b, loc, result); err == nil {
+		err = err2
+	}
+	return err
 }
 
 func (b *Interpreter) lookup(ctx []IContext, loc errlog.LocationRange, name string) (*ExprValue, *errlog.Error) {
+	result, err := b.lookupOptional(ctx, loc, name)
+	if err != nil || result != nil {
+		return result, err
+	}
+	return nil, b.errlog.LogError(errlog.ErrorUnknownMethod, loc, name)
+}
+
+// Like lookup, but returns nil without logging an error if the name is not known.
+func (b *Interpreter) lookupOptional(ctx []IContext, loc errlog.LocationRange, name string) (*ExprValue, *errlog.Error) {
 	for i := len(ctx) - 1; i >= 0; i-- {
 		result, err := ctx[i].Lookup(b, loc, name)
 		if err != nil || result != nil {
 			return result, err
 		}
 	}
-	return nil, b.errlog.LogError(errlog.ErrorUnknownMethod, loc, name)
+	return nil, nil
 }
 
 func (b *Interpreter) evalExpression(ctx []IContext, expr parser.IExpression) (*ExprValue, *errlog.Error) {
 	switch t := expr.(type) {
 	case *parser.DotExpression:
-		panic("TODO dot")
+		return nil, b.errlog.LogError(errlog.ErrorUnsupportedExpression, parser.ExpressionLocation(t))
 	case *parser.ContextExpression:
 		newctx, err := b.evalToContext(ctx, t.Object)
 		if err != nil {
@@ -372,10 +600,11 @@ func (b *Interpreter) evalExpression(ctx []IContext, expr parser.IExpression) (*
 		// fmt.Printf("Context %T\n", newctx)
 		ctx = append(ctx, newctx)
 		err = b.processStatements(ctx, t.Statements)
-		if err != nil {
-			return nil, err
+		// Close the context even in case of errors.
+		// Otherwise its tracks remain unconnected which causes subsequent errors.
+		if err2 := newctx.Close(b); err == nil {
+			err = err2
 		}
-		err = newctx.Close(b)
 		return &ExprValue{Type: contextType, Context: newctx}, err
 	case *parser.CallExpression:
 		f, err := b.evalExpression(ctx, t.Func)
@@ -383,17 +612,33 @@ func (b *Interpreter) evalExpression(ctx []IContext, expr parser.IExpression) (*
 			return nil, err
 		}
 		if f.Type != funcType {
-			return nil, b.errlog.LogError(errlog.ErrorNotAMethod, errlog.LocationRange{})
+			return nil, b.errlog.LogError(errlog.ErrorNotAMethod, parser.ExpressionLocation(t.Func))
 		}
-		return f.FuncValue.Func(b, ctx, errlog.LocationRange{}, t.Arguments...)
+		return f.FuncValue.Func(b, ctx, t.Location, t.Arguments...)
 	case *parser.IdentifierExpression:
-		ident, err := b.lookup(ctx, errlog.LocationRange{}, t.Identifier.StringValue)
+		if t.Identifier.Quoted {
+			// Quoted identifiers which do not name anything evaluate to their name,
+			// e.g. to define a track mark.
+			ident, err := b.lookupOptional(ctx, t.Identifier.Location, t.Identifier.StringValue)
+			if err != nil || ident != nil {
+				return ident, err
+			}
+			return &ExprValue{Type: stringType, StringValue: t.Identifier.StringValue}, nil
+		}
+		ident, err := b.lookup(ctx, t.Identifier.Location, t.Identifier.StringValue)
 		if err != nil {
 			return nil, err
 		}
 		return ident, nil
 	case *parser.BinaryExpression:
 		return b.evalBinaryExpression(ctx, t)
+	case *parser.UnaryExpression:
+		// The only unary operator is the minus sign
+		val, err := b.evalToFloat(ctx, t.Operand)
+		if err != nil {
+			return nil, err
+		}
+		return &ExprValue{Type: numberType, NumberValue: -val}, nil
 	case *parser.DimensionExpression:
 		return b.evalDimensionExpression(ctx, t)
 	case *parser.ConstantExpression:
@@ -406,13 +651,15 @@ func (b *Interpreter) evalExpression(ctx []IContext, expr parser.IExpression) (*
 			result.Type = numberType
 			// TODO: Check range
 			result.NumberValue, _ = t.Value.FloatValue.Float64()
+		} else if t.Value.Kind == parser.TokenString {
+			result.Type = stringType
+			result.StringValue = t.Value.StringValue
 		}
 		return result, nil
 	case *parser.VectorExpression:
 		return b.evalVectorExpression(ctx, t)
 	}
-	fmt.Printf("Type %T\n", expr)
-	panic("TODO")
+	return nil, b.errlog.LogError(errlog.ErrorUnsupportedExpression, parser.ExpressionLocation(expr))
 }
 
 func (b *Interpreter) evalVectorExpression(ctx []IContext, ast *parser.VectorExpression) (*ExprValue, *errlog.Error) {
@@ -436,7 +683,7 @@ func (b *Interpreter) evalDimensionExpression(ctx []IContext, ast *parser.Dimens
 	case "mm", "deg":
 		return left, nil
 	case "cm":
-		val, err := b.ToFloat(left, errlog.LocationRange{})
+		val, err := b.ToFloat(left, parser.ExpressionLocation(ast.Value))
 		if err != nil {
 			return nil, err
 		}
@@ -444,7 +691,7 @@ func (b *Interpreter) evalDimensionExpression(ctx []IContext, ast *parser.Dimens
 		result.NumberValue = val * 10
 		return result, nil
 	case "m":
-		val, err := b.ToFloat(left, errlog.LocationRange{})
+		val, err := b.ToFloat(left, parser.ExpressionLocation(ast.Value))
 		if err != nil {
 			return nil, err
 		}
@@ -452,7 +699,7 @@ func (b *Interpreter) evalDimensionExpression(ctx []IContext, ast *parser.Dimens
 		result.NumberValue = val * 1000
 		return result, nil
 	}
-	panic("Oooops")
+	return nil, b.errlog.LogError(errlog.ErrorIllegalUnit, ast.Dimension.Location, ast.Dimension.StringValue)
 }
 
 func (b *Interpreter) evalBinaryExpression(ctx []IContext, ast *parser.BinaryExpression) (*ExprValue, *errlog.Error) {
@@ -484,47 +731,117 @@ func (b *Interpreter) evalBinaryExpression(ctx []IContext, ast *parser.BinaryExp
 		return nil, err
 	}
 
-	switch ast.Op.Kind {
+	if ast.Op.Kind == parser.TokenAsterisk && left.Type == numberType && right.Type != numberType {
+		return b.evalRepeatExpression(ctx, ast, left, right)
+	}
+	result, err := evalOperator(left, right, ast.Op)
+	if err != nil {
+		// The operators of ExprValue do not log their errors
+		b.errlog.AddError(err)
+	}
+	return result, err
+}
+
+func evalOperator(left *ExprValue, right *ExprValue, op *parser.Token) (*ExprValue, *errlog.Error) {
+	switch op.Kind {
 	case parser.TokenLogicalAnd:
-		return left.LogicalAnd(right, ast.Op.Location)
+		return left.LogicalAnd(right, op.Location)
 	case parser.TokenLogicalOr:
-		return left.LogicalOr(right, ast.Op.Location)
+		return left.LogicalOr(right, op.Location)
 	case parser.TokenEqual:
-		return left.Equal(right, ast.Op.Location)
+		return left.Equal(right, op.Location)
 	case parser.TokenNotEqual:
-		return left.NotEqual(right, ast.Op.Location)
+		return left.NotEqual(right, op.Location)
 	case parser.TokenLessOrEqual:
-		return left.LessOrEqual(right, ast.Op.Location)
+		return left.LessOrEqual(right, op.Location)
 	case parser.TokenGreaterOrEqual:
-		return left.GreaterOrEqual(right, ast.Op.Location)
+		return left.GreaterOrEqual(right, op.Location)
 	case parser.TokenLess:
-		return left.Less(right, ast.Op.Location)
+		return left.Less(right, op.Location)
 	case parser.TokenGreater:
-		return left.Greater(right, ast.Op.Location)
+		return left.Greater(right, op.Location)
 	case parser.TokenPlus:
-		return left.Plus(right, ast.Op.Location)
+		return left.Plus(right, op.Location)
 	case parser.TokenDash:
-		return left.Minus(right, ast.Op.Location)
+		return left.Minus(right, op.Location)
 	case parser.TokenAsterisk:
-		return left.Mul(right, ast.Op.Location)
+		return left.Mul(right, op.Location)
 	case parser.TokenSlash:
-		return left.Div(right, ast.Op.Location)
+		return left.Div(right, op.Location)
 	case parser.TokenPercent:
-		return left.Rem(right, ast.Op.Location)
+		return left.Rem(right, op.Location)
 	case parser.TokenAmpersand:
-		return left.BinaryAnd(right, ast.Op.Location)
+		return left.BinaryAnd(right, op.Location)
 	case parser.TokenPipe:
-		return left.BinaryOr(right, ast.Op.Location)
+		return left.BinaryOr(right, op.Location)
 	case parser.TokenCaret:
-		return left.BinaryXor(right, ast.Op.Location)
+		return left.BinaryXor(right, op.Location)
 	case parser.TokenBitClear:
-		return left.BinaryAndNot(right, ast.Op.Location)
+		return left.BinaryAndNot(right, op.Location)
 	case parser.TokenShiftLeft:
-		return left.Lsh(right, ast.Op.Location)
+		return left.Lsh(right, op.Location)
 	case parser.TokenShiftRight:
-		return left.Rsh(right, ast.Op.Location)
+		return left.Rsh(right, op.Location)
+	}
+	return nil, errlog.NewError(errlog.ErrorUnsupportedExpression, op.Location)
+}
+
+// Upper bound of n in `n * track`. Larger counts are most likely typos.
+const maxRepeatCount = 1000
+
+// Evaluates `n * track` by creating n connected copies of the track or tracks.
+// `first` is the already evaluated right-hand side of the expression.
+func (b *Interpreter) evalRepeatExpression(ctx []IContext, ast *parser.BinaryExpression, count *ExprValue, first *ExprValue) (*ExprValue, *errlog.Error) {
+	loc := ast.Op.Location
+	outer, ok := ctx[len(ctx)-1].(*TracksContext)
+	if !ok {
+		return nil, b.errlog.LogError(errlog.ErrorNoTrackInRepeatExpression, loc)
+	}
+	n := count.NumberValue
+	if n != math.Trunc(n) || n <= 0 || n > maxRepeatCount {
+		return nil, b.errlog.LogError(errlog.ErrorIllegalRepeatCount, loc, strconv.FormatFloat(n, 'f', -1, 64), strconv.Itoa(maxRepeatCount))
+	}
+	rep := NewTracksContext(outer.layer)
+	rep.group = outer.group
+	rep.location = loc
+	for i := 0; i < int(n); i++ {
+		value := first
+		if i > 0 {
+			var err *errlog.Error
+			if value, err = b.evalExpression(ctx, ast.Right); err != nil {
+				return nil, err
+			}
+		}
+		value, err := b.expandFunc(ctx, value, parser.ExpressionLocation(ast.Right))
+		if err != nil {
+			return nil, err
+		}
+		if !isTrackValue(value) {
+			return nil, b.errlog.LogError(errlog.ErrorNoTrackInRepeatExpression, loc)
+		}
+		if err = rep.Process(b, loc, value); err != nil {
+			return nil, err
+		}
+	}
+	if err := rep.Close(b); err != nil {
+		return nil, err
+	}
+	return &ExprValue{Type: contextType, Context: rep}, nil
+}
+
+// Returns true if the value is a track, a turnout or a sequence of tracks.
+func isTrackValue(value *ExprValue) bool {
+	if value == nil || value.Type != contextType {
+		return false
+	}
+	switch t := value.Context.(type) {
+	case *TracksContext, *TurnoutContext:
+		return true
+	case *ValueContext:
+		_, ok := t.Value.(*tracks.Track)
+		return ok
 	}
-	panic("Oooops")
+	return false
 }
 
 /*
@@ -546,7 +863,7 @@ func (b *Interpreter) expandFunc(ctx []IContext, f *ExprValue, loc errlog.Locati
 func (b *Interpreter) ToFloat(e *ExprValue, loc errlog.LocationRange) (float64, *errlog.Error) {
 	if e.Type == funcType {
 		var err *errlog.Error
-		e, err = e.FuncValue.Func(b, nil, errlog.LocationRange{})
+		e, err = e.FuncValue.Func(b, nil, loc)
 		if err != nil {
 			return 0, err
 		}
@@ -560,7 +877,7 @@ func (b *Interpreter) ToFloat(e *ExprValue, loc errlog.LocationRange) (float64,
 func (b *Interpreter) ToBool(e *ExprValue, loc errlog.LocationRange) (bool, *errlog.Error) {
 	if e.Type == funcType {
 		var err *errlog.Error
-		e, err = e.FuncValue.Func(b, nil, errlog.LocationRange{})
+		e, err = e.FuncValue.Func(b, nil, loc)
 		if err != nil {
 			return false, err
 		}
@@ -580,7 +897,7 @@ func (b *Interpreter) ToBool(e *ExprValue, loc errlog.LocationRange) (bool, *err
 func (b *Interpreter) ToVector(e *ExprValue, loc errlog.LocationRange) ([]*ExprValue, *errlog.Error) {
 	if e.Type == funcType {
 		var err *errlog.Error
-		e, err = e.FuncValue.Func(b, nil, errlog.LocationRange{})
+		e, err = e.FuncValue.Func(b, nil, loc)
 		if err != nil {
 			return nil, err
 		}
@@ -594,7 +911,7 @@ func (b *Interpreter) ToVector(e *ExprValue, loc errlog.LocationRange) ([]*ExprV
 func (b *Interpreter) ToString(e *ExprValue, loc errlog.LocationRange) (string, *errlog.Error) {
 	if e.Type == funcType {
 		var err *errlog.Error
-		e, err = e.FuncValue.Func(b, nil, errlog.LocationRange{})
+		e, err = e.FuncValue.Func(b, nil, loc)
 		if err != nil {
 			return "", err
 		}
@@ -608,7 +925,7 @@ func (b *Interpreter) ToString(e *ExprValue, loc errlog.LocationRange) (string,
 func (b *Interpreter) ToContext(e *ExprValue, loc errlog.LocationRange) (IContext, *errlog.Error) {
 	if e.Type == funcType {
 		var err *errlog.Error
-		e, err = e.FuncValue.Func(b, nil, errlog.LocationRange{})
+		e, err = e.FuncValue.Func(b, nil, loc)
 		if err != nil {
 			return nil, err
 		}
@@ -624,7 +941,19 @@ func (b *Interpreter) evalToFloat(ctx []IContext, expr parser.IExpression) (floa
 	if err != nil {
 		return 0, err
 	}
-	return b.ToFloat(val, errlog.LocationRange{})
+	return b.ToFloat(val, parser.ExpressionLocation(expr))
+}
+
+func (b *Interpreter) evalToFloats(ctx []IContext, exprs []parser.IExpression) ([]float64, *errlog.Error) {
+	var result []float64
+	for _, expr := range exprs {
+		f, err := b.evalToFloat(ctx, expr)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, f)
+	}
+	return result, nil
 }
 
 func (b *Interpreter) evalToString(ctx []IContext, expr parser.IExpression) (string, *errlog.Error) {
@@ -632,7 +961,7 @@ func (b *Interpreter) evalToString(ctx []IContext, expr parser.IExpression) (str
 	if err != nil {
 		return "", err
 	}
-	return b.ToString(val, errlog.LocationRange{})
+	return b.ToString(val, parser.ExpressionLocation(expr))
 }
 
 func (b *Interpreter) evalToContext(ctx []IContext, expr parser.IExpression) (IContext, *errlog.Error) {
@@ -640,5 +969,5 @@ func (b *Interpreter) evalToContext(ctx []IContext, expr parser.IExpression) (IC
 	if err != nil {
 		return nil, err
 	}
-	return b.ToContext(val, errlog.LocationRange{})
+	return b.ToContext(val, parser.ExpressionLocation(expr))
 }
@@ -1,21 +1,49 @@
 package interpreter
 
 import (
+	"math"
+	"strings"
 	"testing"
 
 	"github.com/weistn/ferrovia/errlog"
+	"github.com/weistn/ferrovia/model"
 	"github.com/weistn/ferrovia/model/tracks"
 	"github.com/weistn/ferrovia/parser"
 )
 
+// Parses and interprets the data. All errors are logged to the returned error log.
+func interpret(data string) (*model.Model, *errlog.ErrorLog) {
+	tracks.InitRoco()
+	e := errlog.NewErrorLog()
+	fileId := e.AddFile(errlog.NewSourceFile("data"))
+	file := parser.NewParser(e).Parse(fileId, data)
+	return NewInterpreter(e).ProcessStatics(file), e
+}
+
+// Like interpret, but fails the test if there are errors.
+func check(t *testing.T, data string) *model.Model {
+	m, e := interpret(data)
+	if e.HasErrors() {
+		t.Fatal(e.ToString())
+	}
+	return m
+}
+
 var data string = `
+tracks Ausfahrt {
+	G1
+	"A"
+	G1
+}
+
 tracks {
-	mountain
-    Ausfahrt
     @(120 mm, 120 mm, 0 mm, 180 deg)
     G1
+    Ausfahrt
     G1
     3 * R6
+    "B"
+    Spindel(R5)
     ` + "`" + `Gleis 1` + "`" + `
 }
 
@@ -23,6 +51,12 @@ tracks Spindel(radius) {
 	6 * radius
 }
 
+tracks {
+	layer("mountain")
+	@(0 mm, 0 mm, 0 mm, 90 deg)
+	G1
+}
+
 layer mountain {
 	color("red")
 }
@@ -34,31 +68,15 @@ ground {
 }`
 
 func TestInterpreter(t *testing.T) {
-	tracks.InitRoco()
-	e := errlog.NewErrorLog()
-
-	// Parse
-	fileId := e.AddFile(errlog.NewSourceFile("data"))
-	p := parser.NewParser(e)
-	file := p.Parse(fileId, data)
-	if e.HasErrors() {
-		e.Print()
-		t.Fail()
-	}
-
-	// Interpret
-	b := NewInterpreter(e)
-	model := b.ProcessStatics(file)
-	if model == nil {
-		t.Fatal("No model")
+	model := check(t, data)
+	if len(model.Tracks.Layers[""].Tracks) != 13 {
+		t.Fatalf("Tracks missing: %v", len(model.Tracks.Layers[""].Tracks))
 	}
-	if e.HasErrors() {
-		e.Print()
-		t.Fail()
+	if l := model.Tracks.Layers["mountain"]; l == nil || len(l.Tracks) != 1 || l.Color != "red" {
+		t.Fatal("Tracks of layer mountain missing")
 	}
-	if len(model.Tracks.Layers[""].Tracks) != 16 {
-		println(len(model.Tracks.Layers[""].Tracks))
-		t.Fatal("Tracks missing")
+	if model.Tracks.GetMark("Gleis 1") == nil {
+		t.Fatal("Missing mark Gleis 1")
 	}
 	if model.Tracks.GetMark("A") == nil {
 		t.Fatal("Missing mark A")
@@ -73,3 +91,486 @@ func TestInterpreter(t *testing.T) {
 		t.Fatal("Mark B not connected")
 	}
 }
+
+var markData string = `
+tracks {
+	@(0 mm, 0 mm, 0 mm, 90 deg)
+	` + "`" + `Start` + "`" + `
+	G1
+	G1
+	"Middle"
+	R6
+	"End"
+}
+
+tracks {
+	@(0 mm, 500 mm, 0 mm, 90 deg)
+	G1
+	"Start"
+}`
+
+func TestTrackMarks(t *testing.T) {
+	model, e := interpret(markData)
+	if !e.HasErrors() {
+		t.Fatal("Expected an error for the duplicate mark")
+	}
+	for _, name := range []string{"Start", "Middle", "End"} {
+		if model.Tracks.GetMark(name) == nil {
+			t.Fatal("Missing mark " + name)
+		}
+	}
+	if model.Tracks.GetMark("Start").Connection.IsConnected() {
+		t.Fatal("Mark Start should be at the open end of the tracks")
+	}
+	if !model.Tracks.GetMark("Middle").Connection.IsConnected() {
+		t.Fatal("Mark Middle not connected")
+	}
+	if model.Tracks.GetMark("Middle").Track().Geometry.Name != "G1" {
+		t.Fatal("Mark Middle is on the wrong track")
+	}
+	if len(model.Tracks.Marks()) != 3 {
+		t.Fatal("Wrong number of marks")
+	}
+}
+
+var templateData string = `
+tracks Curve(radius, straight) {
+	radius radius straight
+}
+
+tracks {
+	@(0 mm, 0 mm, 0 mm, 90 deg)
+	Curve(R6, G1)
+	Curve(R5, G4)
+}`
+
+func TestTracksTemplate(t *testing.T) {
+	model := check(t, templateData)
+	ts := model.Tracks.Layers[""].Tracks
+	if len(ts) != 6 {
+		t.Fatal("Wrong number of tracks")
+	}
+	for i, name := range []string{"R6", "R6", "G1", "R5", "R5", "G4"} {
+		if ts[i].Geometry.Name != name || ts[i].Group != "Curve" {
+			t.Fatal("Unexpected track " + ts[i].Geometry.Name)
+		}
+	}
+	if ts[2].SecondConnection().Opposite != ts[3].FirstConnection() {
+		t.Fatal("Copies of the template are not connected")
+	}
+}
+
+func TestTemplateOutsideTracks(t *testing.T) {
+	_, e := interpret("tracks T() {\n\tG1\n}\n\nground {\n\ttop(T)\n\tleft(0 cm)\n}\n")
+	if !e.HasErrors() {
+		t.Fatal("Expected an error")
+	}
+}
+
+var repeatData string = `
+tracks Spindel(radius) {
+	6 * radius
+}
+
+tracks {
+	@(0 mm, 0 mm, 0 mm, 90 deg)
+	4 * L6
+	2 * Spindel(R5)
+	G1
+}`
+
+func TestRepeat(t *testing.T) {
+	model := check(t, repeatData)
+	ts := model.Tracks.Layers[""].Tracks
+	if len(ts) != 17 {
+		t.Fatal("Wrong number of tracks")
+	}
+	for i := 0; i < len(ts)-1; i++ {
+		if ts[i].SecondConnection().Opposite != ts[i+1].FirstConnection() {
+			t.Fatal("Repeated tracks are not connected")
+		}
+	}
+
+	for _, n := range []string{"0", "2.5", "1001", "1000000000"} {
+		_, e := interpret(strings.Replace(repeatData, "4 * L6", n+" * L6", 1))
+		if !strings.Contains(e.ToString(), "The repeat count "+n+" is not an integer between 1 and 1000") {
+			t.Fatal("Missing error for repeat count " + n + "\n" + e.ToString())
+		}
+	}
+}
+
+var threeWayData string = `
+tracks {
+	@(0 mm, 0 mm, 0 mm, 90 deg)
+	DW15 {
+		middle { G1 }
+		right { G4 }
+	}
+	L5
+}`
+
+func TestThreeWayTurnout(t *testing.T) {
+	model := check(t, threeWayData)
+	ts := model.Tracks.Layers[""].Tracks
+	turnout := ts[0]
+	if turnout.Connection(2).Opposite.Track.Geometry.Name != "G1" || turnout.Connection(3).Opposite.Track.Geometry.Name != "G4" {
+		t.Fatal("Branches are not connected")
+	}
+	// The remaining tracks continue on the left connection
+	if turnout.Connection(1).Opposite.Track.Geometry.Name != "R5" {
+		t.Fatal("Tracks do not continue on the free connection")
+	}
+}
+
+var faultyData string = `
+tracks {
+	@(0 mm, 0 mm, 0 mm, 90 deg)
+	WR10 {
+		left { G1 }
+		right { G1 }
+	}
+	Foo
+	WR10 {
+		middle { G1 }
+	}
+}`
+
+func TestFaultyTurnouts(t *testing.T) {
+	_, e := interpret(faultyData)
+	// All errors are reported, not only the first one
+	str := e.ToString()
+	for _, msg := range []string{"no free connection", "Foo", "no middle connection"} {
+		if !strings.Contains(str, msg) {
+			t.Fatal("Missing error: " + msg)
+		}
+	}
+}
+
+var namedTracksData string = `
+tracks Loop {
+	R6
+}
+
+tracks Loop {
+	G1
+}
+
+tracks {
+	@(0 mm, 0 mm, 0 mm, 90 deg)
+	Loop
+	G1
+	Loop
+	G1
+	Loop
+}`
+
+func TestNamedTracks(t *testing.T) {
+	_, e := interpret(namedTracksData)
+	// Named tracks can close a loop, but neither be defined twice nor be continued once the loop is closed
+	str := e.ToString()
+	for _, msg := range []string{"data 6:8: Another identifier of the same name `Loop`", "data 15:2: The track has been connected twice"} {
+		if !strings.Contains(str, msg) {
+			t.Fatal("Missing error: " + msg + "\n" + str)
+		}
+	}
+}
+
+// Malformed input is reported at its location instead of crashing the interpreter
+func TestMalformedInput(t *testing.T) {
+	for _, c := range []struct{ data, msg string }{
+		{"tracks {\n\t@(0 mm, 0 mm, 0 mm, 90 deg)\n\tWR10 {\n\t\tleft { G1 }\n\t\tright { G1 }\n\t}\n\tG1\n}", "data 3:2: The turnout WR10 has no free connection left"},
+		{"tracks {\n\t@(0 mm, 0 mm, 0 mm, 90 deg)\n\tG1 * G1\n}", "data 3:5: Type mismatch"},
+	} {
+		_, e := interpret(c.data)
+		if str := e.ToString(); !strings.Contains(str, c.msg) || strings.Contains(str, "Internal error") {
+			t.Fatal("Missing error: " + c.msg + "\n" + str)
+		}
+	}
+	// Vectors can be tested for equality, but not be ordered
+	a := &ExprValue{Type: vectorType, VectorValue: []*ExprValue{{Type: numberType, NumberValue: 1}}}
+	b := &ExprValue{Type: vectorType, VectorValue: []*ExprValue{{Type: numberType, NumberValue: 2}}}
+	if r, err := a.Equal(b, errlog.LocationRange{}); err != nil || r.NumberValue != 0 {
+		t.Fatal("Vectors must not be equal")
+	}
+	if _, err := a.Less(b, errlog.LocationRange{}); err == nil {
+		t.Fatal("Expected a type mismatch")
+	}
+}
+
+func TestErrorLocation(t *testing.T) {
+	_, e := interpret("tracks {\n\t@(0 mm, 0 mm, 0 mm, 0 deg)\n\tG1 Foo\n}\n")
+	if str := e.ToString(); !strings.HasPrefix(str, "data 3:5: ") {
+		t.Fatal("Wrong location: " + str)
+	}
+}
+
+var heightData string = `
+tracks {
+	@(0 mm, 0 mm, 10 mm, 90 deg)
+	incline(2)
+	2 * L6
+	incline(0)
+	G1
+	height(0 mm)
+	G1
+}`
+
+func TestHeights(t *testing.T) {
+	model := check(t, heightData)
+	ts := model.Tracks.Layers[""].Tracks
+	if len(ts) != 4 {
+		t.Fatal("Wrong number of tracks")
+	}
+	height := func(con *tracks.TrackConnection) float64 {
+		pos, _ := con.Track.Location.Connection(con.Track.ConnectionIndex(con), con.Track.Geometry)
+		return pos[2]
+	}
+	rise := 0.02 * ts[0].Geometry.Length()
+	if math.Abs(height(ts[0].FirstConnection())-10) > 0.01 || math.Abs(height(ts[1].SecondConnection())-10-2*rise) > 0.01 {
+		t.Fatal("Wrong height of the incline")
+	}
+	if math.Abs(ts[2].Incline-(-10-2*rise)/230*100) > 0.01 || math.Abs(height(ts[2].SecondConnection())) > 0.01 {
+		t.Fatal("Wrong height of the ramp")
+	}
+	if ts[3].Incline != 0 || math.Abs(height(ts[3].SecondConnection())) > 0.01 {
+		t.Fatal("Wrong height after the ramp")
+	}
+}
+
+var systemData string = `system "maerklin-c"
+
+tracks {
+	@(0 mm, 0 mm, 0 mm, 90 deg)
+	G24188
+	R24130
+}`
+
+func TestTrackSystem(t *testing.T) {
+	model := check(t, systemData)
+	ts := model.Tracks.Layers[""].Tracks
+	if len(ts) != 2 || ts[0].Geometry.Name != "G24188" || ts[1].Geometry.Name != "R24130" {
+		t.Fatal("Wrong tracks")
+	}
+	// Roco pieces are not known in the Märklin C system
+	_, e := interpret(strings.Replace(systemData, "G24188", "G1", 1))
+	if !e.HasErrors() {
+		t.Fatal("Expected an error")
+	}
+}
+
+var geometryData string = `geometry G75 {
+	straight(75 mm)
+}
+
+geometry R700 {
+	curve(700 mm, 15 deg)
+	reversed("L700")
+}
+
+geometry Y {
+	line(100 mm)
+	arc(500 mm, 10 deg)
+	connection(0 mm, 0 mm, 0 deg)
+	connection(0 mm, 100 mm, 180 deg)
+	connection(-7.596 mm, 86.824 mm, 190 deg)
+	option(0, 1)
+	option(0, 2)
+}
+
+tracks {
+	@(0 mm, 0 mm, 0 mm, 90 deg)
+	G75 R700 L700 Y G75
+}`
+
+func TestGeometry(t *testing.T) {
+	model := check(t, geometryData)
+	ts := model.Tracks.Layers[""].Tracks
+	if len(ts) != 5 || ts[0].Geometry.Name != "G75" || ts[2].Geometry != ts[1].Geometry || ts[3].Geometry.OutgoingConnectionCount != 2 || !ts[3].Geometry.Custom {
+		t.Fatal("Wrong tracks")
+	}
+	// The curves form an S, hence the last track heads along the x-axis again
+	pos, _ := ts[4].Location.Connection(1, ts[4].Geometry)
+	a := 15 * math.Pi / 180
+	if math.Abs(pos[0]-250-2*700*math.Sin(a)) > 0.01 || math.Abs(pos[1]-2*700*(1-math.Cos(a))) > 0.01 {
+		t.Fatalf("Wrong position %v", pos)
+	}
+
+	// Pieces of the track system cannot be redefined
+	_, e := interpret(strings.Replace(geometryData, "G75 {", "G1 {", 1))
+	if !strings.Contains(e.ToString(), "The geometry G1 is invalid") {
+		t.Fatal("Expected an error")
+	}
+}
+
+var flexData string = `tracks {
+	@(0 mm, 0 mm, 0 mm, 90 deg) G1 flex G1 @(1500 mm, 300 mm, 0 mm, 90 deg)
+}
+
+tracks {
+	@(0 mm, 1000 mm, 0 mm, 90 deg) G1 flex G1 @(500 mm, 2000 mm, 50 mm, 270 deg)
+}`
+
+func TestFlex(t *testing.T) {
+	model := check(t, flexData)
+	ts := model.Tracks.Layers[""].Tracks
+	for _, i := range []int{1, 4} {
+		flex := ts[i]
+		end, angle := flex.Location.Connection(1, flex.Geometry)
+		next, nextAngle := ts[i+1].Location.Connection(0, ts[i+1].Geometry)
+		if end.Sub(next).Length() > 0.01 || math.Abs(math.Mod(angle-nextAngle+360, 360)-180) > 0.01 {
+			t.Fatalf("The flex track %v does not meet the next track: %v %v and %v %v", i, end, angle, next, nextAngle)
+		}
+		for _, p := range flex.Geometry.Paths {
+			if arc, ok := p.(*tracks.TrackGeometryArc); ok && arc.Radius < tracks.DefaultFlexRadius {
+				t.Fatalf("Radius %v is too small", arc.Radius)
+			}
+		}
+	}
+	if math.Abs(ts[4].Incline-50/ts[4].Geometry.Length()*100) > 0.01 {
+		t.Fatal("Wrong incline of the flex track")
+	}
+
+	// The U-turn requires a radius of 250 mm or less
+	_, e := interpret(strings.Replace(flexData, "2000 mm", "1500 mm", 1))
+	if !strings.Contains(e.ToString(), "The flex track cannot join its neighbours") {
+		t.Fatal("Expected an error")
+	}
+
+	// The neighbours meet already, hence no loop must be laid in between
+	_, e = interpret("tracks {\n\t@(0 mm, 0 mm, 0 mm, 90 deg) G1 flex G1 @(460 mm, 0 mm, 0 mm, 90 deg)\n}")
+	if !strings.Contains(e.ToString(), "data 2:33: The flex track is not needed") {
+		t.Fatal("Expected an error: " + e.ToString())
+	}
+}
+
+var switchData string = `
+switchboard {
+    ,---@
+@---/----@
+    W1
+}
+
+tracks {
+	@(0 mm, 0 mm, 0 mm, 90 deg)
+	G1
+	"W1"
+	WR10 {
+		right { G1 }
+	}
+	G1
+	"end"
+}`
+
+func TestSwitchBinding(t *testing.T) {
+	model := check(t, switchData)
+	cell := model.Switchboards[0].Switch("W1")
+	if cell == nil || cell.Turnout == nil || cell.Turnout.Geometry.Name != "WR10" {
+		t.Fatal("The switch is not bound to the turnout")
+	}
+	diverging := cell.Turnout.IsDiverging()
+	cell.Turnout.Switch()
+	if cell.Turnout.IsDiverging() == diverging {
+		t.Fatal("The turnout did not switch")
+	}
+
+	// Labels must name marks next to exactly one turnout, and each turnout is operated by one switch only
+	for label, msg := range map[string]string{"W2": "no mark of this name", "end": "not next to exactly one turnout"} {
+		_, e := interpret(strings.Replace(switchData, "    W1\n", "    "+label+"\n", 1))
+		if !strings.Contains(e.ToString(), msg) {
+			t.Fatal("Missing error: " + msg)
+		}
+	}
+	_, e := interpret(strings.Replace(switchData, "    W1\n", "    W1 W1\n@------/--@\n@------'\n", 1))
+	if !strings.Contains(e.ToString(), "operated by more than one switch") {
+		t.Fatal("Missing error: operated by more than one switch")
+	}
+}
+
+var blockData string = `
+switchboard {
+@---BBBB---@
+     B1
+}
+
+tracks {
+	@(0 mm, 0 mm, 0 mm, 90 deg)
+	"a" G1 G1 "b" G1 "c"
+}
+
+block B1 {
+	from("a")
+	to("b")
+	contact(1)
+	contact(2)
+	detector(3)
+}
+`
+
+func TestBlocks(t *testing.T) {
+	model := check(t, blockData)
+	b := model.Block("B1")
+	if b == nil || len(b.Tracks) != 2 || len(b.Contacts) != 2 || b.Detector != 3 {
+		t.Fatal("The block has not been defined correctly")
+	}
+	if math.Abs(b.Length-2*b.Tracks[0].Geometry.Length()) > 0.001 {
+		t.Fatalf("Wrong block length %v", b.Length)
+	}
+	if cell := model.Switchboards[0].Cell(4, 0); cell.Text != "B1" {
+		t.Fatal("The switchboard block is not labelled")
+	}
+
+	// Blocks must not overlap, and their labels and addresses must be valid
+	for _, c := range []struct{ old, new, msg string }{
+		{"detector(3)\n}", "detector(3)\n}\n\nblock B2 {\n\tfrom(\"c\")\n\tto(\"a\")\n}", "The blocks B1 and B2 share the track G1"},
+		{"detector(3)", "detector(0.5)", "not a positive integer"},
+		{"to(\"b\")", "to(\"d\")", "Unknown mark `d`"},
+		{"block B1", "block B5", "no block of this name"},
+	} {
+		_, e := interpret(strings.Replace(blockData, c.old, c.new, 1))
+		if !strings.Contains(e.ToString(), c.msg) {
+			t.Fatal("Missing error: " + c.msg)
+		}
+	}
+
+	// Marks inside a track cannot be routed
+	e := errlog.NewErrorLog()
+	e.AddFile(errlog.NewSourceFile("data"))
+	b = model.Block("B1")
+	b.Tracks[0].AddMark(0.5, "inside")
+	in := NewInterpreter(e)
+	in.model = model
+	ctx := NewBlockContext("B2", errlog.LocationRange{})
+	ctx.from, ctx.to = "a", "inside"
+	if ctx.Close(in) == nil || !strings.Contains(e.ToString(), "the mark lies inside a track") {
+		t.Fatal("Missing error: the mark lies inside a track")
+	}
+}
+
+func TestDecoders(t *testing.T) {
+	model := check(t, switchData+"\ndecoders {\n\tturnout(\"W1\", 12)\n}\n")
+	if a := model.TurnoutAddresses["W1"]; len(a) != 1 || a[0] != 12 {
+		t.Fatal("The turnout has no address")
+	}
+
+	for name, msg := range map[string]string{"W2": "Unknown mark `W2`", "end": "not next to exactly one turnout"} {
+		_, e := interpret(switchData + "\ndecoders {\n\tturnout(\"" + name + "\", 12)\n}\n")
+		if !strings.Contains(e.ToString(), msg) {
+			t.Fatal("Missing error: " + msg)
+		}
+	}
+
+	// Three-way turnouts require two decoders, other turnouts one
+	data := strings.Replace(threeWayData, "\tDW15", "\t\"W3\"\n\tDW15", 1)
+	model = check(t, data+"\ndecoders {\n\tturnout(\"W3\", 12, 13)\n}\n")
+	if a := model.TurnoutAddresses["W3"]; len(a) != 2 || a[0] != 12 || a[1] != 13 {
+		t.Fatal("The turnout has no addresses")
+	}
+	_, e := interpret(data + "\ndecoders {\n\tturnout(\"W3\", 12)\n}\n")
+	if !strings.Contains(e.ToString(), "has 3 options and requires two decoder addresses") {
+		t.Fatal("Missing error: requires two decoder addresses " + e.ToString())
+	}
+	_, e = interpret(switchData + "\ndecoders {\n\tturnout(\"W1\", 12, 13)\n}\n")
+	if !strings.Contains(e.ToString(), "has 2 options and requires one decoder address") {
+		t.Fatal("Missing error: requires one decoder address " + e.ToString())
+	}
+}
@@ -21,10 +21,10 @@ func NewLayerContext(layer *tracks.TrackLayer) *LayerContext {
 				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "1")
 			}
 			arg, err := b.evalExpression(c, args[0])
-			if arg != nil {
+			if err != nil {
 				return nil, err
 			}
-			ctx.layer.Color, err = b.ToString(arg, loc)
+			ctx.layer.Color, err = b.ToString(arg, parser.ExpressionLocation(args[0]))
 			return nil, err
 		},
 	}
@@ -0,0 +1,107 @@
+package interpreter
+
+import (
+	"github.com/weistn/ferrovia/errlog"
+	"github.com/weistn/ferrovia/model"
+	"github.com/weistn/ferrovia/parser"
+)
+
+// Implements IContext
+type RulesContext struct {
+	rules *model.Rules
+	funcs map[string]*FuncValue
+}
+
+func NewRulesContext(rules *model.Rules) *RulesContext {
+	ctx := &RulesContext{rules: rules, funcs: make(map[string]*FuncValue)}
+	ctx.funcs["minRadius"] = &FuncValue{
+		Name: "minRadius",
+		Func: func(b *Interpreter, c []IContext, loc errlog.LocationRange, args ...parser.IExpression) (*ExprValue, *errlog.Error) {
+			if len(args) == 1 {
+				var err *errlog.Error
+				ctx.rules.MinRadius, err = b.evalToFloat(c, args[0])
+				return nil, err
+			}
+			if len(args) != 2 {
+				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "2")
+			}
+			layer, err := b.evalToString(c, args[0])
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := b.model.Tracks.Layers[layer]; !ok {
+				return nil, b.errlog.LogError(errlog.ErrorUnknownLayer, parser.ExpressionLocation(args[0]), layer)
+			}
+			radius, err := b.evalToFloat(c, args[1])
+			if err != nil {
+				return nil, err
+			}
+			if ctx.rules.LayerMinRadius == nil {
+				ctx.rules.LayerMinRadius = make(map[string]float64)
+			}
+			ctx.rules.LayerMinRadius[layer] = radius
+			return nil, nil
+		},
+	}
+	ctx.funcs["straightBetweenCurves"] = &FuncValue{
+		Name: "straightBetweenCurves",
+		Func: func(b *Interpreter, c []IContext, loc errlog.LocationRange, args ...parser.IExpression) (*ExprValue, *errlog.Error) {
+			if len(args) != 1 {
+				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "1")
+			}
+			var err *errlog.Error
+			ctx.rules.StraightBetweenCurves, err = b.evalToFloat(c, args[0])
+			return nil, err
+		},
+	}
+	ctx.funcs["noTurnoutAfterCurve"] = &FuncValue{
+		Name: "noTurnoutAfterCurve",
+		Func: func(b *Interpreter, c []IContext, loc errlog.LocationRange, args ...parser.IExpression) (*ExprValue, *errlog.Error) {
+			if len(args) != 0 {
+				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "0")
+			}
+			ctx.rules.NoTurnoutAfterCurve = true
+			return nil, nil
+		},
+	}
+	ctx.funcs["maxGrade"] = &FuncValue{
+		Name: "maxGrade",
+		Func: func(b *Interpreter, c []IContext, loc errlog.LocationRange, args ...parser.IExpression) (*ExprValue, *errlog.Error) {
+			if len(args) != 1 {
+				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "1")
+			}
+			var err *errlog.Error
+			ctx.rules.MaxGrade, err = b.evalToFloat(c, args[0])
+			return nil, err
+		},
+	}
+	ctx.funcs["mainLine"] = &FuncValue{
+		Name: "mainLine",
+		Func: func(b *Interpreter, c []IContext, loc errlog.LocationRange, args ...parser.IExpression) (*ExprValue, *errlog.Error) {
+			if len(args) != 1 {
+				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "1")
+			}
+			var err *errlog.Error
+			ctx.rules.MainLine, err = b.evalToString(c, args[0])
+			// The mark is checked once all tracks are known
+			b.mainLine = parser.ExpressionLocation(args[0])
+			return nil, err
+		},
+	}
+	return ctx
+}
+
+func (c *RulesContext) Lookup(b *Interpreter, loc errlog.LocationRange, name string) (*ExprValue, *errlog.Error) {
+	if f, ok := c.funcs[name]; ok {
+		return &ExprValue{Type: funcType, FuncValue: f}, nil
+	}
+	return nil, nil
+}
+
+func (c *RulesContext) Process(b *Interpreter, loc errlog.LocationRange, value *ExprValue) *errlog.Error {
+	return b.errlog.LogError(errlog.ErrorIllegalInThisContext, loc)
+}
+
+func (c *RulesContext) Close(b *Interpreter) *errlog.Error {
+	return nil
+}
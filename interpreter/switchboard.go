@@ -5,6 +5,7 @@ import (
 
 	"github.com/weistn/ferrovia/errlog"
 	. "github.com/weistn/ferrovia/model/switchboard"
+	"github.com/weistn/ferrovia/model/tracks"
 )
 
 type scanDirection int
@@ -173,9 +174,80 @@ func processASCIIStructure(lines []string, loc errlog.LocationRange, log *errlog
 			}
 		}
 	}
+
+	// Switches are labelled by a word in a neighbouring cell
+	for y := 0; y < l.LineCount; y++ {
+		for x := 0; x < l.ColumnCount; x++ {
+			cell := l.Cell(x, y)
+			if !cell.IsSwitch() {
+				continue
+			}
+			var labels []string
+			for _, label := range []string{wordAt(l, x, y-1), wordAt(l, x, y+1), wordAt(l, x-1, y), wordAt(l, x+1, y)} {
+				if label != "" {
+					labels = append(labels, label)
+				}
+			}
+			if len(labels) > 1 {
+				addASCIIStructureError(log, errlog.ErrorMalformedLayout, loc, x, y, "Switch has more than one label")
+			} else if len(labels) == 1 {
+				cell.Text = labels[0]
+			}
+		}
+	}
+
+	// Blocks are labelled by a word alongside one of their cells
+	for y := 0; y < l.LineCount; y++ {
+		for x := 0; x < l.ColumnCount; x++ {
+			cell := l.Cell(x, y)
+			if !cell.IsBlock() || cell.Anchor != nil {
+				continue
+			}
+			var labels []string
+			for i := 0; i < 4; i++ {
+				var words []string
+				if cell.Type == TrackHorizontalBlock {
+					words = []string{wordAt(l, x+i, y-1), wordAt(l, x+i, y+1)}
+				} else {
+					words = []string{wordAt(l, x-1, y+i), wordAt(l, x+1, y+i)}
+				}
+				for _, label := range words {
+					if label != "" && (len(labels) == 0 || labels[len(labels)-1] != label) {
+						labels = append(labels, label)
+					}
+				}
+			}
+			if len(labels) > 1 {
+				addASCIIStructureError(log, errlog.ErrorMalformedLayout, loc, x, y, "Block has more than one label")
+			} else if len(labels) == 1 {
+				cell.Text = labels[0]
+			}
+		}
+	}
 	return l
 }
 
+// Returns the word of letters and digits which contains the cell at x,y and which is not part of the tracks.
+// Returns the empty string if there is no such word.
+func wordAt(l *ASCIISwitchboard, x int, y int) string {
+	isWord := func(x int) bool {
+		c := l.Cell(x, y)
+		return c != nil && c.Type == UnprocessedCell && (unicode.IsLetter(c.Rune) || unicode.IsDigit(c.Rune))
+	}
+	if !isWord(x) {
+		return ""
+	}
+	start := x
+	for isWord(start - 1) {
+		start--
+	}
+	var word []rune
+	for ; isWord(start); start++ {
+		word = append(word, l.Cell(start, y).Rune)
+	}
+	return string(word)
+}
+
 func processCells(l *ASCIISwitchboard, cells []*ASCIISwitchboardCell, pos int, dir scanDirection, log *errlog.ErrorLog) {
 	inc := 1
 	if dir == scanLeft || dir == scanUpwards {
@@ -309,7 +381,52 @@ func processCells(l *ASCIISwitchboard, cells []*ASCIISwitchboardCell, pos int, d
 	}
 }
 
+// Binds the labelled switches of all switchboards to the turnouts next to the marks named by the labels.
+func (b *Interpreter) bindSwitches() {
+	bound := make(map[*tracks.Track]errlog.LocationRange)
+	for _, sb := range b.model.Switchboards {
+		for i := range sb.Cells {
+			cell := &sb.Cells[i]
+			if !cell.IsSwitch() || cell.Text == "" {
+				continue
+			}
+			loc := cellLocation(sb.Location, cell.X, cell.Y)
+			mark := b.model.Tracks.GetMark(cell.Text)
+			if mark == nil {
+				b.errlog.LogError(errlog.ErrorSwitchUnknownMark, loc, cell.Text)
+				continue
+			}
+			t := mark.Turnout()
+			if t == nil {
+				b.errlog.LogError(errlog.ErrorSwitchWithoutTurnout, loc, cell.Text)
+				continue
+			}
+			if other, ok := bound[t]; ok {
+				b.errlog.LogError(errlog.ErrorTurnoutBoundTwice, loc, cell.Text).AddRelated(other)
+				continue
+			}
+			bound[t] = loc
+			cell.Turnout = t
+		}
+	}
+}
+
+// Checks that the labelled blocks of all switchboards name blocks of the layout.
+func (b *Interpreter) bindBlocks() {
+	for _, sb := range b.model.Switchboards {
+		for i := range sb.Cells {
+			cell := &sb.Cells[i]
+			if cell.IsBlock() && cell.Text != "" && b.model.Block(cell.Text) == nil {
+				b.errlog.LogError(errlog.ErrorUnknownBlock, cellLocation(sb.Location, cell.X, cell.Y), cell.Text)
+			}
+		}
+	}
+}
+
+func cellLocation(loc errlog.LocationRange, x int, y int) errlog.LocationRange {
+	return errlog.EncodeLocationRange(loc.File(), loc.Line()+y, loc.Position()+x, loc.Line()+y, loc.Position()+x)
+}
+
 func addASCIIStructureError(log *errlog.ErrorLog, code errlog.ErrorCode, loc errlog.LocationRange, x int, y int, args ...string) *errlog.Error {
-	newloc := errlog.EncodeLocationRange(loc.File(), loc.Line()+y, loc.Position()+x, loc.Line()+y, loc.Position()+x)
-	return log.LogError(code, newloc, args...)
+	return log.LogError(code, cellLocation(loc, x, y), args...)
 }
@@ -0,0 +1,86 @@
+package interpreter
+
+import (
+	"strconv"
+
+	"github.com/weistn/ferrovia/errlog"
+	"github.com/weistn/ferrovia/parser"
+)
+
+// A named tracks block with a parameter list.
+// Each call of the template creates a fresh copy of its tracks.
+type TracksTemplate struct {
+	ast  *parser.Tracks
+	fn   FuncValue
+	busy bool
+}
+
+// Implements IContext
+// Binds the parameters of a TracksTemplate to the arguments of a call.
+type ScopeContext struct {
+	identifiers map[string]*ExprValue
+}
+
+func NewTracksTemplate(ast *parser.Tracks) *TracksTemplate {
+	tmpl := &TracksTemplate{ast: ast}
+	tmpl.fn = FuncValue{
+		Name: ast.Name.StringValue,
+		Func: func(b *Interpreter, c []IContext, loc errlog.LocationRange, args ...parser.IExpression) (*ExprValue, *errlog.Error) {
+			if len(args) != len(ast.Parameters) {
+				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, strconv.Itoa(len(ast.Parameters)))
+			}
+			if tmpl.busy {
+				return nil, b.errlog.LogError(errlog.ErrorRecursiveTracks, loc, ast.Name.StringValue)
+			}
+			// Arguments are evaluated in the context of the caller
+			scope := NewScopeContext()
+			for i, p := range ast.Parameters {
+				arg, err := b.evalExpression(c, args[i])
+				if err != nil {
+					return nil, err
+				}
+				scope.identifiers[p.Name.StringValue] = arg
+			}
+			// Place the tracks in the layer of the caller
+			layer := b.model.Tracks.Layers[""]
+			if len(c) != 0 {
+				if t, ok := c[len(c)-1].(*TracksContext); ok {
+					layer = t.layer
+				}
+			}
+			ctx := NewTracksContext(layer)
+			ctx.location = loc
+			ctx.group = ast.Name.StringValue
+			tmpl.busy = true
+			err := b.processStatements([]IContext{b.ctx, scope, ctx}, ast.Expressions)
+			tmpl.busy = false
+			if err != nil {
+				return nil, err
+			}
+			if err = ctx.Close(b); err != nil {
+				return nil, err
+			}
+			return &ExprValue{Type: contextType, Context: ctx}, nil
+		},
+	}
+	return tmpl
+}
+
+func NewScopeContext() *ScopeContext {
+	return &ScopeContext{identifiers: make(map[string]*ExprValue)}
+}
+
+func (ctx *ScopeContext) Lookup(b *Interpreter, loc errlog.LocationRange, name string) (*ExprValue, *errlog.Error) {
+	if v, ok := ctx.identifiers[name]; ok {
+		return v, nil
+	}
+	return nil, nil
+}
+
+func (ctx *ScopeContext) Process(b *Interpreter, loc errlog.LocationRange, value *ExprValue) *errlog.Error {
+	return b.errlog.LogError(errlog.ErrorIllegalInThisContext, loc)
+}
+
+func (ctx *ScopeContext) Close(b *Interpreter) *errlog.Error {
+	return nil
+}
@@ -1,6 +1,9 @@
 package interpreter
 
 import (
+	"fmt"
+	"strconv"
+
 	"github.com/weistn/ferrovia/errlog"
 	"github.com/weistn/ferrovia/model/tracks"
 	"github.com/weistn/ferrovia/parser"
@@ -14,22 +17,71 @@ type pendingAnchor struct {
 	location errlog.LocationRange
 }
 
+// A named mark in a tracks block.
+// It is attached to the connection between the preceding and the following track.
+type pendingMark struct {
+	name     string
+	location errlog.LocationRange
+}
+
+// Changes the incline of the following tracks.
+type pendingIncline struct {
+	incline  float64
+	location errlog.LocationRange
+}
+
+// A target height in a tracks block.
+// Like a mark, it refers to the connection between the preceding and the following track.
+type pendingHeight struct {
+	height   float64
+	location errlog.LocationRange
+}
+
+// A sequence of tracks which must rise or fall to reach a certain height.
+// The incline of the tracks can only be computed once the height at the start of the ramp is known.
+type ramp struct {
+	start    *tracks.TrackConnection
+	tracks   []*tracks.Track
+	height   float64
+	location errlog.LocationRange
+	resolved bool
+}
+
+// A flexible track. Its shape is computed once the tracks at both ends are located.
+type flex struct {
+	track *tracks.Track
+	// The minimum radius of its curves
+	radius float64
+}
+
 // Implements IContext
 type TracksContext struct {
 	// The currently selected layer
 	layer *tracks.TrackLayer
-	// A list of *Track or *pendingAnchor instances.
+	// A list of *Track, *TracksContext, *pendingAnchor, *pendingMark,
+	// *pendingIncline or *pendingHeight instances.
 	// The list is processed upon Close().
 	elements []interface{}
 	// Populate after Close()
 	first *tracks.TrackConnection
 	// Populate after Close()
-	last      *tracks.TrackConnection
-	atFunc    FuncValue
-	layerFunc FuncValue
+	last *tracks.TrackConnection
+	// The tracks connecting first and last. Populated after Close()
+	chain []*tracks.Track
+	// True if the context determines the incline of its tracks itself.
+	// Populated after Close()
+	graded      bool
+	atFunc      FuncValue
+	layerFunc   FuncValue
+	inclineFunc FuncValue
+	heightFunc  FuncValue
+	flexFunc    FuncValue
 	// A cache
 	trackFuncs map[string]*FuncValue
 	location   errlog.LocationRange
+	// Name of the named tracks block or the empty string.
+	// It is recorded in all tracks created in this context.
+	group string
 }
 
 // Implements IContext
@@ -58,7 +110,7 @@ func NewTracksContext(layer *tracks.TrackLayer) *TracksContext {
 			}
 			l, ok := b.model.Tracks.Layers[name]
 			if !ok {
-				return nil, b.errlog.LogError(errlog.ErrorUnknownLayer, loc, name)
+				return nil, b.errlog.LogError(errlog.ErrorUnknownLayer, parser.ExpressionLocation(args[0]), name)
 			}
 			ctx.layer = l
 			return nil, nil
@@ -68,7 +120,7 @@ func NewTracksContext(layer *tracks.TrackLayer) *TracksContext {
 		Name: "@",
 		Func: func(b *Interpreter, c []IContext, loc errlog.LocationRange, args ...parser.IExpression) (*ExprValue, *errlog.Error) {
 			if len(args) != 4 {
-				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "1")
+				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "4")
 			}
 			x, err := b.evalToFloat(c, args[0])
 			if err != nil {
@@ -91,6 +143,59 @@ func NewTracksContext(layer *tracks.TrackLayer) *TracksContext {
 			return &ExprValue{Type: contextType, Context: &ValueContext{Value: &pendingAnchor{x: x, y: y, z: z, angle: angle, location: loc}}}, nil
 		},
 	}
+	ctx.inclineFunc = FuncValue{
+		Name: "incline",
+		Func: func(b *Interpreter, c []IContext, loc errlog.LocationRange, args ...parser.IExpression) (*ExprValue, *errlog.Error) {
+			if len(args) != 1 {
+				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "1")
+			}
+			incline, err := b.evalToFloat(c, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return &ExprValue{Type: contextType, Context: &ValueContext{Value: &pendingIncline{incline: incline, location: loc}}}, nil
+		},
+	}
+	ctx.heightFunc = FuncValue{
+		Name: "height",
+		Func: func(b *Interpreter, c []IContext, loc errlog.LocationRange, args ...parser.IExpression) (*ExprValue, *errlog.Error) {
+			if len(args) != 1 {
+				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "1")
+			}
+			height, err := b.evalToFloat(c, args[0])
+			if err != nil {
+				return nil, err
+			}
+			return &ExprValue{Type: contextType, Context: &ValueContext{Value: &pendingHeight{height: height, location: loc}}}, nil
+		},
+	}
+	ctx.flexFunc = FuncValue{
+		Name: "flex",
+		Func: func(b *Interpreter, c []IContext, loc errlog.LocationRange, args ...parser.IExpression) (*ExprValue, *errlog.Error) {
+			if len(args) > 1 {
+				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "1")
+			}
+			f := &flex{radius: tracks.DefaultFlexRadius}
+			if len(args) == 1 {
+				var err *errlog.Error
+				if f.radius, err = b.evalToFloat(c, args[0]); err != nil {
+					return nil, err
+				}
+			}
+			// Like other tracks, the flex track belongs to the innermost tracks context
+			tc := ctx
+			if len(c) != 0 {
+				if t, ok := c[len(c)-1].(*TracksContext); ok {
+					tc = t
+				}
+			}
+			f.track = tracks.NewTrack(tc.layer, 0, tracks.NewFlexPlaceholder(), false)
+			f.track.SourceLocation = loc
+			f.track.Group = tc.group
+			b.flexes = append(b.flexes, f)
+			return &ExprValue{Type: contextType, Context: &ValueContext{Value: f.track}}, nil
+		},
+	}
 	return ctx
 }
 
@@ -100,12 +205,18 @@ func (c *TracksContext) Lookup(b *Interpreter, loc errlog.LocationRange, name st
 		return &ExprValue{Type: funcType, FuncValue: &c.layerFunc}, nil
 	case "@":
 		return &ExprValue{Type: funcType, FuncValue: &c.atFunc}, nil
+	case "incline":
+		return &ExprValue{Type: funcType, FuncValue: &c.inclineFunc}, nil
+	case "height":
+		return &ExprValue{Type: funcType, FuncValue: &c.heightFunc}, nil
+	case "flex":
+		return &ExprValue{Type: funcType, FuncValue: &c.flexFunc}, nil
 	default:
 		if f, ok := c.trackFuncs[name]; ok {
 			return &ExprValue{Type: funcType, FuncValue: f}, nil
 		}
 		// Is it a track type?
-		_, ok := tracks.TrackFactories[name]
+		_, ok := b.model.Tracks.Catalog.TrackFactory(name)
 		if !ok {
 			return nil, nil
 		}
@@ -115,12 +226,21 @@ func (c *TracksContext) Lookup(b *Interpreter, loc errlog.LocationRange, name st
 			if len(args) != 0 {
 				return nil, b.errlog.LogError(errlog.ErrorArgumentCountMismatch, loc, "0")
 			}
-			newTrack := c.layer.NewTrack(name)
+			// The track belongs to the tracks context in which it is created.
+			// This is not c if the track type has been passed as argument to a tracks template.
+			tc := c
+			if len(ctx) != 0 {
+				if t, ok := ctx[len(ctx)-1].(*TracksContext); ok {
+					tc = t
+				}
+			}
+			newTrack := tc.layer.NewTrack(name)
 			if newTrack == nil {
 				// A track of this name does not exist.
 				return nil, b.errlog.LogError(errlog.ErrorUnknownTrackType, loc, name)
 			}
 			newTrack.SourceLocation = loc
+			newTrack.Group = tc.group
 			// In case of a turnout, create a TurnoutContext
 			if newTrack.Geometry.IncomingConnectionCount+newTrack.Geometry.OutgoingConnectionCount > 2 {
 				return &ExprValue{Type: contextType, Context: NewTurnoutContext(newTrack)}, nil
@@ -133,6 +253,10 @@ func (c *TracksContext) Lookup(b *Interpreter, loc errlog.LocationRange, name st
 }
 
 func (c *TracksContext) Process(b *Interpreter, loc errlog.LocationRange, value *ExprValue) *errlog.Error {
+	if value.Type == stringType {
+		c.elements = append(c.elements, &pendingMark{name: value.StringValue, location: loc})
+		return nil
+	}
 	if value.Type == contextType {
 		switch t := value.Context.(type) {
 		case *TracksContext:
@@ -146,7 +270,7 @@ func (c *TracksContext) Process(b *Interpreter, loc errlog.LocationRange, value
 			case *tracks.Track:
 				c.elements = append(c.elements, v)
 				return nil
-			case *pendingAnchor:
+			case *pendingAnchor, *pendingIncline, *pendingHeight:
 				c.elements = append(c.elements, v)
 				return nil
 			}
@@ -160,34 +284,83 @@ func (c *TracksContext) Close(b *Interpreter) *errlog.Error {
 	// Connect all tracks
 	//
 	var anchor *pendingAnchor
+	// Marks which precede the first track
+	var marks []*pendingMark
+	// Incline of the following tracks
+	var incline float64
+	// The tracks since the last anchor or height
+	var r *ramp
 	for _, el := range c.elements {
 		switch e := el.(type) {
 		case *tracks.Track:
 			con := e.FirstConnection()
+			// The incline is required to compute the location of the track
+			e.Incline = incline
+			if c.last == nil {
+				if err := b.addMarks(con, marks); err != nil {
+					return err
+				}
+				marks = nil
+			}
 			if anchor != nil {
 				l := tracks.NewTrackLocation(con, tracks.Vec3{anchor.x, anchor.y, anchor.z}, anchor.angle)
 				if !con.Track.SetLocation(l) {
 					return b.errlog.LogError(errlog.ErrorTrackPositionedTwice, e.SourceLocation)
 				}
-				b.tracksWithAnchor = append(b.tracksWithAnchor, con.Track)
+				b.anchors = append(b.anchors, con)
 				anchor = nil
 			}
 			if c.last != nil {
-				c.last.Connect(con)
+				if !c.last.Connect(con) {
+					return b.errlog.LogError(errlog.ErrorTrackConnectedTwice, e.SourceLocation)
+				}
 			} else {
 				c.first = con
 			}
 			c.last = e.SecondConnection()
+			c.chain = append(c.chain, e)
+			if r == nil {
+				r = &ramp{start: con}
+			}
+			r.tracks = append(r.tracks, e)
 		case *TracksContext:
 			if e.first != nil {
+				if !e.graded {
+					for _, t := range e.chain {
+						t.Incline = incline
+					}
+				}
+				if c.last == nil {
+					if err := b.addMarks(e.first, marks); err != nil {
+						return err
+					}
+					marks = nil
+				}
+				if anchor != nil {
+					l := tracks.NewTrackLocation(e.first, tracks.Vec3{anchor.x, anchor.y, anchor.z}, anchor.angle)
+					if !e.first.Track.SetLocation(l) {
+						return b.errlog.LogError(errlog.ErrorTrackPositionedTwice, anchor.location)
+					}
+					b.anchors = append(b.anchors, e.first)
+					anchor = nil
+				}
 				if c.last != nil {
-					c.last.Connect(e.first)
+					if !c.last.Connect(e.first) {
+						return b.errlog.LogError(errlog.ErrorTrackConnectedTwice, e.location)
+					}
 				} else {
 					c.first = e.first
 				}
 				c.last = e.last
+				c.chain = append(c.chain, e.chain...)
+				if r == nil {
+					r = &ramp{start: e.first}
+				}
+				r.tracks = append(r.tracks, e.chain...)
 			}
 		case *pendingAnchor:
+			// A ramp starts at the anchor at the latest
+			r = nil
 			if c.last == nil {
 				// Apply to the next track
 				anchor = e
@@ -202,10 +375,69 @@ func (c *TracksContext) Close(b *Interpreter) *errlog.Error {
 				if !c.last.Track.SetLocation(l) {
 					return b.errlog.LogError(errlog.ErrorTrackPositionedTwice, e.location)
 				}
-				b.tracksWithAnchor = append(b.tracksWithAnchor, c.last.Track)
+				b.anchors = append(b.anchors, c.last)
+			}
+		case *pendingMark:
+			if c.last == nil {
+				// Apply to the next track
+				marks = append(marks, e)
+			} else if err := b.addMarks(c.last, []*pendingMark{e}); err != nil {
+				return err
+			}
+		case *pendingIncline:
+			// The tracks before the incline keep their incline
+			r = nil
+			incline = e.incline
+			c.graded = true
+		case *pendingHeight:
+			if r == nil {
+				return b.errlog.LogError(errlog.ErrorHeightWithoutTrack, e.location, strconv.FormatFloat(e.height, 'f', -1, 64))
 			}
+			r.height = e.height
+			r.location = e.location
+			b.ramps = append(b.ramps, r)
+			r = nil
+			c.graded = true
 		default:
-			panic("Ooooops")
+			return b.errlog.LogError(errlog.ErrorInternal, c.location, fmt.Sprintf("unknown element %T", e))
+		}
+	}
+	if len(marks) != 0 {
+		return b.errlog.LogError(errlog.ErrorTrackMarkWithoutTrack, marks[0].location, marks[0].name)
+	}
+	return nil
+}
+
+// Returns the unresolved ramp which starts at the connection con or nil.
+func (b *Interpreter) rampAt(con *tracks.TrackConnection) *ramp {
+	for _, r := range b.ramps {
+		if r.start == con && !r.resolved {
+			return r
+		}
+	}
+	return nil
+}
+
+// Computes the incline of all tracks of the ramp given the height at its start.
+func (r *ramp) resolve(height float64) {
+	r.resolved = true
+	var length float64
+	for _, t := range r.tracks {
+		length += t.Geometry.Length()
+	}
+	if length == 0 {
+		return
+	}
+	incline := (r.height - height) / length * 100
+	for _, t := range r.tracks {
+		t.Incline = incline
+	}
+}
+
+func (b *Interpreter) addMarks(con *tracks.TrackConnection, marks []*pendingMark) *errlog.Error {
+	for _, m := range marks {
+		if !con.AddMark(m.name) {
+			return b.errlog.LogError(errlog.ErrorTrackMarkDefinedTwice, m.location, m.name)
 		}
 	}
 	return nil
@@ -220,141 +452,164 @@ func (c *TurnoutContext) Lookup(b *Interpreter, loc errlog.LocationRange, name s
 	case "left":
 		c.left = NewTracksContext(c.track.Layer)
 		c.left.location = loc
+		c.left.group = c.track.Group
 		return &ExprValue{Type: contextType, Context: c.left}, nil
 	case "right":
 		c.right = NewTracksContext(c.track.Layer)
 		c.right.location = loc
+		c.right.group = c.track.Group
 		return &ExprValue{Type: contextType, Context: c.right}, nil
 	case "middle":
 		c.middle = NewTracksContext(c.track.Layer)
 		c.middle.location = loc
+		c.middle.group = c.track.Group
 		return &ExprValue{Type: contextType, Context: c.middle}, nil
 	case "backleft":
 		c.backleft = NewTracksContext(c.track.Layer)
 		c.backleft.location = loc
+		c.backleft.group = c.track.Group
 		return &ExprValue{Type: contextType, Context: c.backleft}, nil
 	case "backright":
 		c.backright = NewTracksContext(c.track.Layer)
 		c.backright.location = loc
+		c.backright.group = c.track.Group
 		return &ExprValue{Type: contextType, Context: c.backright}, nil
 	case "backmiddle":
 		c.backmiddle = NewTracksContext(c.track.Layer)
 		c.backmiddle.location = loc
+		c.backmiddle.group = c.track.Group
 		return &ExprValue{Type: contextType, Context: c.backmiddle}, nil
 	}
 	return nil, nil
 }
 
-func (c *TurnoutContext) connect(c1, c2 *tracks.TrackConnection) {
+func (c *TurnoutContext) connect(b *Interpreter, c1, c2 *tracks.TrackConnection) *errlog.Error {
 	if c2 == nil {
-		return
+		return nil
 	}
-	c1.Connect(c2)
+	if !c1.Connect(c2) {
+		return b.errlog.LogError(errlog.ErrorTrackConnectedTwice, c2.Track.SourceLocation)
+	}
+	return nil
 }
 
 func (c *TurnoutContext) Process(b *Interpreter, loc errlog.LocationRange, value *ExprValue) *errlog.Error {
-	// TODO: Throw error on unacceptable values
+	// Only the branches of the turnout are allowed here
+	if value.Type == contextType {
+		if t, ok := value.Context.(*TracksContext); ok {
+			for _, branch := range []*TracksContext{c.left, c.right, c.middle, c.backleft, c.backright, c.backmiddle} {
+				if t == branch {
+					return nil
+				}
+			}
+		}
+	}
+	return b.errlog.LogError(errlog.ErrorIllegalInThisContext, loc)
+}
+
+// Connects the branches of a turnout with one incoming connection.
+// Both lists hold one entry per outgoing connection, sorted from left to right.
+// Branches in `backward` join the turnout when it is passed in reverse direction.
+// The tracks following the turnout continue along the first turnout option
+// which leads to an outgoing connection without a branch.
+func (c *TurnoutContext) connectBranches(b *Interpreter, forward []*TracksContext, backward []*TracksContext) *errlog.Error {
+	hasForward := false
+	hasBackward := false
+	for i := range forward {
+		hasForward = hasForward || forward[i] != nil
+		hasBackward = hasBackward || backward[i] != nil
+	}
+	if hasForward && hasBackward {
+		return b.errlog.LogError(errlog.ErrorTurnoutFrontAndBack, c.track.SourceLocation, c.track.Geometry.Name)
+	}
+	branches := forward
+	if hasBackward {
+		branches = backward
+		c.track.Reverse()
+	}
+	incoming := c.track.Geometry.IncomingConnectionCount
+	option := -1
+	for i, o := range c.track.Geometry.TurnoutOptions {
+		if branches[o.To-incoming] == nil {
+			option = i
+			break
+		}
+	}
+	if option == -1 {
+		return b.errlog.LogError(errlog.ErrorTurnoutNoFreeConnection, c.track.SourceLocation, c.track.Geometry.Name)
+	}
+	c.track.SelectedTurnoutOption = option
+	for i, branch := range branches {
+		if branch == nil {
+			continue
+		}
+		var err *errlog.Error
+		if hasBackward {
+			err = c.connect(b, c.track.Connection(incoming+i), branch.last)
+		} else {
+			err = c.connect(b, c.track.Connection(incoming+i), branch.first)
+		}
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
 func (c *TurnoutContext) Close(b *Interpreter) *errlog.Error {
-	if c.track.Geometry.IncomingConnectionCount == 1 && c.track.Geometry.OutgoingConnectionCount == 2 {
+	geo := c.track.Geometry
+	if geo.IncomingConnectionCount == 1 && geo.OutgoingConnectionCount == 2 {
+		// A normal turnout
 		if c.middle != nil || c.backmiddle != nil {
-			panic("TOOD: The 'middle' connection is not available on this track")
+			return b.errlog.LogError(errlog.ErrorTurnoutNoMiddleConnection, c.track.SourceLocation, geo.Name)
 		}
-		// A normal turnout
-		if c.backleft != nil || c.backright != nil {
-			// Reverse track
-			if c.left != nil || c.right != nil {
-				panic("TODO: Track is not a crossing")
-			}
-			if c.backleft != nil && c.backright != nil {
-				panic("TODO: No free connection left on turnout")
-			}
-			c.track.Reverse()
-			if c.backright != nil {
-				c.connect(c.track.Connection(1), c.backright.last)
+		return c.connectBranches(b, []*TracksContext{c.left, c.right}, []*TracksContext{c.backright, c.backleft})
+	} else if geo.IncomingConnectionCount == 1 && geo.OutgoingConnectionCount == 3 {
+		// A three-way turnout
+		return c.connectBranches(b, []*TracksContext{c.left, c.middle, c.right}, []*TracksContext{c.backright, c.backmiddle, c.backleft})
+	} else if geo.IncomingConnectionCount == 2 && geo.OutgoingConnectionCount == 2 {
+		if c.middle != nil || c.backmiddle != nil {
+			return b.errlog.LogError(errlog.ErrorTurnoutNoMiddleConnection, c.track.SourceLocation, geo.Name)
+		}
+		if (c.backleft != nil && c.backright != nil) || (c.left != nil && c.right != nil) {
+			return b.errlog.LogError(errlog.ErrorTurnoutNoFreeConnection, c.track.SourceLocation, geo.Name)
+		}
+		if len(geo.TurnoutOptions) == 2 {
+			// A non-switching crossing
+			if c.backright == nil && c.left == nil {
+				c.track.SelectedTurnoutOption = 0
+			} else if c.backleft == nil && c.right == nil {
 				c.track.SelectedTurnoutOption = 1
 			} else {
-				c.connect(c.track.Connection(2), c.backleft.last)
-				c.track.SelectedTurnoutOption = 0
+				return b.errlog.LogError(errlog.ErrorTurnoutNoFreeConnection, c.track.SourceLocation, geo.Name)
 			}
 		} else {
-			if c.left != nil && c.right != nil {
-				panic("TODO: No free connection left on turnout")
-			}
-			if c.left != nil {
-				c.connect(c.track.Connection(1), c.left.first)
-				c.track.SelectedTurnoutOption = 1
-			} else if c.right != nil {
-				c.connect(c.track.Connection(2), c.right.first)
+			// A switching crossing
+			if c.backright == nil && c.left == nil {
 				c.track.SelectedTurnoutOption = 0
+			} else if c.backright == nil && c.right == nil {
+				c.track.SelectedTurnoutOption = 1
+			} else if c.backleft == nil && c.left == nil {
+				c.track.SelectedTurnoutOption = 2
+			} else if c.backleft == nil && c.right == nil {
+				c.track.SelectedTurnoutOption = 3
 			}
 		}
-	} else if c.track.Geometry.IncomingConnectionCount == 1 && c.track.Geometry.OutgoingConnectionCount == 3 {
-		// A three-way turnout
-		panic("TODO")
-	} else if c.track.Geometry.IncomingConnectionCount == 2 && c.track.Geometry.OutgoingConnectionCount == 2 && len(c.track.Geometry.TurnoutOptions) == 2 {
-		// A non-switching crossing
-		if c.middle != nil || c.backmiddle != nil {
-			panic("TOOD: The 'middle' connection is not available on this track")
-		}
-		if c.backleft != nil && c.backright != nil {
-			panic("TODO: No free connection left on turnout")
-		}
-		if c.left != nil && c.right != nil {
-			panic("TODO: No free connection left on turnout")
-		}
-		// A switching crossing
-		if c.backright == nil && c.left == nil {
-			c.track.SelectedTurnoutOption = 0
-		} else if c.backleft == nil && c.right == nil {
-			c.track.SelectedTurnoutOption = 1
-		} else {
-			panic("TODO: No free connection left on turnout")
-		}
+		var err *errlog.Error
 		if c.left != nil {
-			c.connect(c.track.Connection(2), c.left.first)
+			err = c.connect(b, c.track.Connection(2), c.left.first)
 		} else if c.right != nil {
-			c.connect(c.track.Connection(3), c.right.first)
-		}
-		if c.backright != nil {
-			c.connect(c.track.Connection(0), c.backright.last)
-		} else if c.backleft != nil {
-			c.connect(c.track.Connection(1), c.backleft.last)
+			err = c.connect(b, c.track.Connection(3), c.right.first)
 		}
-	} else if c.track.Geometry.IncomingConnectionCount == 2 && c.track.Geometry.OutgoingConnectionCount == 2 {
-		if c.middle != nil || c.backmiddle != nil {
-			panic("TOOD: The 'middle' connection is not available on this track")
-		}
-		// A switching crossing
-		if c.backright == nil && c.left == nil {
-			c.track.SelectedTurnoutOption = 0
-		} else if c.backright == nil && c.right == nil {
-			c.track.SelectedTurnoutOption = 1
-		} else if c.backleft == nil && c.left == nil {
-			c.track.SelectedTurnoutOption = 2
-		} else if c.backleft == nil && c.right == nil {
-			c.track.SelectedTurnoutOption = 3
-		}
-		if c.backleft != nil && c.backright != nil {
-			panic("TODO: No free connection left on turnout")
-		}
-		if c.left != nil && c.right != nil {
-			panic("TODO: No free connection left on turnout")
-		}
-		if c.left != nil {
-			c.connect(c.track.Connection(2), c.left.first)
-		} else if c.right != nil {
-			c.connect(c.track.Connection(3), c.right.first)
+		if err != nil {
+			return err
 		}
 		if c.backright != nil {
-			c.connect(c.track.Connection(0), c.backright.last)
+			err = c.connect(b, c.track.Connection(0), c.backright.last)
 		} else if c.backleft != nil {
-			c.connect(c.track.Connection(1), c.backleft.last)
+			err = c.connect(b, c.track.Connection(1), c.backleft.last)
 		}
-	} else {
-		panic("Ooops")
+		return err
 	}
-	return nil
+	return b.errlog.LogError(errlog.ErrorUnsupportedTurnout, c.track.SourceLocation, geo.Name)
 }
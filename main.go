@@ -4,47 +4,46 @@ import (
 	"errors"
 	"flag"
 	"fmt"
-	"io/fs"
-	"net/http"
 	"os"
 
-	"github.com/fsnotify/fsnotify"
+	"github.com/weistn/ferrovia/analysis"
 	"github.com/weistn/ferrovia/errlog"
 	"github.com/weistn/ferrovia/interpreter"
 	"github.com/weistn/ferrovia/model"
 	"github.com/weistn/ferrovia/model/tracks"
 	"github.com/weistn/ferrovia/parser"
-	"github.com/weistn/ferrovia/view/switchboard"
-	"github.com/weistn/ferrovia/view/tracks2d"
-	"github.com/weistn/goui"
-
-	"embed"
 )
 
-type WindowAPI struct {
+// A sub-command of the command line front end.
+// The run function receives the command line arguments following the name of the command
+// and returns the exit code of the process.
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) int
 }
 
-/*
-var demodata string = `{
-"tracks": [
-	{"c": 5, "r": 5, "kind": 20},
-	{"c": 6, "r": 5, "kind": 20},
-	{"c": 7, "r": 5, "kind": 20}
-],
-"columns": 20,
-"rows": 20
-}`
-*/
-
-//go:embed view/*.html view/*.css view/*.js view/fonts view/switchboard/*.js view/switchboard/*.css view/tracks2d/*.js view/tracks2d/*.css
-var uiFS embed.FS
+var commands = []*command{
+	{name: "check", usage: "check [flags] file.via\n\tParses and interprets the file and reports all errors and warnings.", run: runCheck},
+	{name: "export", usage: "export [flags] file.via\n\tWrites the track plan or the switchboard to a file.", run: runExport},
+	{name: "route", usage: "route -from mark [-to mark] [flags] file.via\n\tLists the shortest routes between marks and the turnout options they require.", run: runRoute},
+	{name: "serve", usage: "serve [flags] file.via\n\tShows the file in the browser and reloads it whenever it changes.", run: runServe},
+}
 
-var window *goui.Window
+func usage() {
+	fmt.Fprint(os.Stderr, "Usage: ferrovia <command> [arguments]\n\nThe commands are:\n\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  ferrovia %v\n\n", cmd.usage)
+	}
+	fmt.Fprint(os.Stderr, "Use \"ferrovia <command> -h\" for more information about a command.\n")
+}
 
-func loadFile(name string) (*model.Model, error) {
+// Loads, interprets and analyses a *.via file.
+// The returned log is nil if the file could not be read.
+func loadFile(name string, cfg *analysis.Config) (*model.Model, *errlog.ErrorLog, error) {
 	data, err := os.ReadFile(name)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	log := errlog.NewErrorLog()
@@ -53,106 +52,85 @@ func loadFile(name string) (*model.Model, error) {
 	file := p.Parse(fileId, string(data))
 	if log.HasErrors() {
 		log.Print()
-		return nil, errors.New("parsing Error")
+		return nil, log, errors.New("parsing Error")
 	}
 
 	b := interpreter.NewInterpreter(log)
 	m := b.ProcessStatics(file)
 	if log.HasErrors() {
 		log.Print()
-		return nil, errors.New("interpreter error")
+		return nil, log, errors.New("interpreter error")
 	}
 
-	return m, nil
+	analysis.Run(m, cfg, log)
+	if log.HasErrors() {
+		log.Print()
+		return nil, log, errors.New("analysis error")
+	}
+
+	return m, log, nil
 }
 
-func showFile(filename string) error {
-	model, err := loadFile(filename)
+// A command line flag which registers the track systems of a catalog file.
+// It can be used more than once.
+type catalogFlag struct{}
+
+func (catalogFlag) String() string {
+	return ""
+}
+
+func (catalogFlag) Set(name string) error {
+	data, err := os.ReadFile(name)
 	if err != nil {
 		return err
 	}
-	model.Name = "Demo"
+	return tracks.RegisterCatalogFile(data)
+}
 
-	canvas := tracks2d.Render(model)
-	if err := window.SendEvent("canvas", canvas); err != nil {
-		fmt.Fprint(os.Stderr, err.Error())
-		return err
+// Parses the flags of a command and returns the name of the *.via file to process.
+// Flags common to all commands are added to the flag set.
+// Returns false if the command line is not valid.
+func parseCommandLine(fs *flag.FlagSet, args []string) (string, bool) {
+	fs.Var(catalogFlag{}, "catalog", "Load additional track systems from a catalog `file`")
+	if err := fs.Parse(args); err != nil {
+		return "", false
 	}
-
-	layout := switchboard.Render(model.Switchboards)
-	if err = window.SendEvent("layout", layout); err != nil {
-		fmt.Fprint(os.Stderr, err.Error())
-		return err
+	if fs.NArg() != 1 {
+		fmt.Fprint(os.Stderr, "Missing command line argument\n")
+		fs.Usage()
+		return "", false
 	}
-	return nil
+	return fs.Arg(0), true
 }
 
 func main() {
 	//
 	// Parse command lines
 	//
+	flag.Usage = usage
 	flag.Parse()
-	if flag.NArg() != 1 {
-		fmt.Fprint(os.Stderr, "Missing command line argument\n")
-		return
+	if flag.NArg() < 1 {
+		usage()
+		os.Exit(2)
 	}
-	filename := flag.Arg(0)
 
 	tracks.InitRoco()
 
-	//
-	// Open UI in browser
-	//
-
-	remote := &WindowAPI{}
-
-	window = goui.NewWindow("/", remote, nil)
-	subfs, err := fs.Sub(uiFS, "view")
-	if err != nil {
-		panic("Embedding failed")
+	name := flag.Arg(0)
+	for _, cmd := range commands {
+		if cmd.name == name {
+			os.Exit(cmd.run(flag.Args()[1:]))
+		}
 	}
-	window.Handle("/", http.FileServer(http.FS(subfs)))
-	err = window.Start()
-	if err != nil {
-		fmt.Fprint(os.Stderr, err.Error())
+	if name == "help" {
+		usage()
 		return
 	}
-
-	//
-	// Watch file
-	//
-	watcher, err := fsnotify.NewWatcher()
-	if err != nil {
-		fmt.Fprint(os.Stderr, "Could not watch file", err)
-		return
-	}
-	defer watcher.Close()
-	err = watcher.Add(filename)
-	if err != nil {
-		fmt.Fprint(os.Stderr, "Could not watch file "+filename, err)
+	// For compatibility, `ferrovia file.via` is the same as `ferrovia serve file.via`
+	if _, err := os.Stat(name); err == nil {
+		os.Exit(runServe(flag.Args()))
 	}
-
-	go func() {
-		showFile(filename)
-		for {
-			select {
-			case event, ok := <-watcher.Events:
-				if !ok {
-					return
-				}
-				// fmt.Fprintf(os.Stderr, "%s %s\n", event.Name, event.Op)
-				if event.Op == fsnotify.Create || event.Op == fsnotify.Write {
-					showFile(filename)
-				}
-			case err, ok := <-watcher.Errors:
-				if !ok {
-					return
-				}
-				fmt.Fprintf(os.Stderr, "error: %v\n", err)
-			}
-		}
-
-	}()
-
-	window.Wait()
+	fmt.Fprintf(os.Stderr, "Unknown command %v\n", name)
+	usage()
+	os.Exit(2)
 }
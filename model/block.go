@@ -0,0 +1,31 @@
+package model
+
+import "github.com/weistn/ferrovia/model/tracks"
+
+// A Block is a section of track between two marks, e.g. a station track or a part of the main line.
+// Digital control locates trains by the block which they occupy.
+type Block struct {
+	Name string
+	From *tracks.TrackMark
+	To   *tracks.TrackMark
+	// The tracks of the shortest route from From to To.
+	Tracks []*tracks.Track
+	// Length in mm of the shortest route from From to To.
+	Length float64
+	// Addresses of the feedback contacts which report trains passing them, e.g. reed contacts or contact tracks.
+	Contacts []int
+	// Address of the occupancy detector which reports trains standing in the block or 0 if there is none.
+	Detector int
+	// Address of the signal at the end of the block, which lets trains leave towards To, or 0 if there is none.
+	Signal int
+}
+
+// Returns the block of the given name or nil.
+func (m *Model) Block(name string) *Block {
+	for _, b := range m.Blocks {
+		if b.Name == name {
+			return b
+		}
+	}
+	return nil
+}
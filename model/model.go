@@ -10,6 +10,28 @@ type Model struct {
 	GroundPlates []*GroundPlate
 	Switchboards []*switchboard.ASCIISwitchboard
 	Tracks       *tracks.TrackSystem
+	Rules        Rules
+	Blocks       []*Block
+	// Addresses of the accessory decoders of turnouts by the name of a mark next to the turnout.
+	// Turnouts with three or four options, e.g. three-way turnouts, have two decoders.
+	TurnoutAddresses map[string][]int
+}
+
+// Design rules declared by a layout. They override the rules configured for the analysis.
+// Zero values denote rules which the layout does not declare.
+type Rules struct {
+	// Minimum radius in mm of all curves.
+	MinRadius float64
+	// Minimum radius in mm of the curves in a layer by layer name. It overrides MinRadius.
+	LayerMinRadius map[string]float64
+	// Minimum length in mm of the straight track between curves turning in opposite directions.
+	StraightBetweenCurves float64
+	// If true, turnouts must not be connected to curves.
+	NoTurnoutAfterCurve bool
+	// Maximum incline in percent.
+	MaxGrade float64
+	// Name of a mark on the main line. All tracks must be reachable from it without reversing.
+	MainLine string
 }
 
 type GroundPlate struct {
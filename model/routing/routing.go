@@ -0,0 +1,235 @@
+package routing
+
+import (
+	"container/heap"
+	"sort"
+
+	"github.com/weistn/ferrovia/model/tracks"
+)
+
+// A Route leads a train from one mark to another.
+type Route struct {
+	From *tracks.TrackMark
+	To   *tracks.TrackMark
+	// The tracks in the order in which the train passes them.
+	Tracks []*tracks.Track
+	// The options which the turnouts on the route must select, in the order in which the train passes them.
+	Settings []Setting
+	// Length in mm.
+	Length float64
+}
+
+// A Setting selects an option of a turnout.
+type Setting struct {
+	Turnout *tracks.Track
+	// Index into the TurnoutOptions of the turnout geometry.
+	Option int
+}
+
+// Returns all routes from one mark to another, sorted by length.
+// A route passes each track at most once. Only marks at track connections can be routed.
+// The routes do not depend on the currently selected turnout options.
+// The number of routes can grow exponentially with the number of turnouts, hence the search stops after max routes.
+// complete is false in this case, and the routes found are not necessarily the shortest ones.
+func Routes(from *tracks.TrackMark, to *tracks.TrackMark, max int) (routes []*Route, complete bool) {
+	if from == to || from.Connection == nil || to.Connection == nil {
+		return nil, true
+	}
+	r := &router{to: to, max: max, visited: make(map[*tracks.Track]bool)}
+	// Trains can leave the mark in both directions
+	for _, con := range []*tracks.TrackConnection{from.Connection, from.Connection.Opposite} {
+		if con == nil {
+			continue
+		}
+		r.route = &Route{From: from, To: to}
+		r.enter(con)
+	}
+	routes = r.routes
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].Length < routes[j].Length
+	})
+	return routes, !r.truncated
+}
+
+// Returns the shortest route from one mark to another or nil if the mark cannot be reached.
+// Unlike Routes, it does not enumerate all routes, hence it is fast on large layouts as well.
+// Like Routes, it passes each track at most once. This may hide a route which passes a track
+// of a shorter, but dead-ended route in the other direction, e.g. via a reversing loop.
+func Shortest(from *tracks.TrackMark, to *tracks.TrackMark) *Route {
+	if from == to || from.Connection == nil || to.Connection == nil {
+		return nil
+	}
+	// Dijkstra's algorithm, where the states are the connections via which trains enter a track
+	q := &queue{}
+	// Trains can leave the mark in both directions
+	for _, con := range []*tracks.TrackConnection{from.Connection, from.Connection.Opposite} {
+		if con != nil {
+			heap.Push(q, &step{in: con})
+		}
+	}
+	entered := make(map[*tracks.TrackConnection]bool)
+	for q.Len() != 0 {
+		s := heap.Pop(q).(*step)
+		if s.in == nil {
+			return s.prev.route(from, to, s.length)
+		}
+		if entered[s.in] {
+			continue
+		}
+		entered[s.in] = true
+		t := s.in.Track
+		index := t.ConnectionIndex(s.in)
+		for i, o := range t.Geometry.TurnoutOptions {
+			var out *tracks.TrackConnection
+			if o.From == index {
+				out = t.Connection(o.To)
+			} else if o.To == index {
+				out = t.Connection(o.From)
+			} else {
+				continue
+			}
+			h := &hop{track: t, option: i, prev: s.prev}
+			length := s.length + t.Geometry.OptionLength(i)
+			if hasMark(out, to) || (out.Opposite != nil && hasMark(out.Opposite, to)) {
+				// The route is complete, but routes via other tracks may still be shorter
+				heap.Push(q, &step{length: length, prev: h})
+				continue
+			}
+			if out.Opposite != nil && !entered[out.Opposite] && !h.passes(out.Opposite.Track) {
+				heap.Push(q, &step{in: out.Opposite, length: length, prev: h})
+			}
+		}
+	}
+	return nil
+}
+
+// Selects the turnout options required by the route.
+func (r *Route) Set() {
+	for _, s := range r.Settings {
+		s.Turnout.SelectedTurnoutOption = s.Option
+	}
+}
+
+// Performs a depth-first search for routes.
+type router struct {
+	to *tracks.TrackMark
+	// The route leading to the track which is currently being searched.
+	route   *Route
+	visited map[*tracks.Track]bool
+	// All routes found so far.
+	routes []*Route
+	// The maximum number of routes.
+	max int
+	// True if the search has stopped because there are more than max routes.
+	truncated bool
+}
+
+// Continues the search on the track of the connection, which a train enters via this connection.
+func (r *router) enter(in *tracks.TrackConnection) {
+	t := in.Track
+	if r.truncated || r.visited[t] {
+		return
+	}
+	r.visited[t] = true
+	defer delete(r.visited, t)
+	index := t.ConnectionIndex(in)
+	for i, o := range t.Geometry.TurnoutOptions {
+		var out *tracks.TrackConnection
+		if o.From == index {
+			out = t.Connection(o.To)
+		} else if o.To == index {
+			out = t.Connection(o.From)
+		} else {
+			continue
+		}
+		route := *r.route
+		route.Tracks = append(append([]*tracks.Track{}, r.route.Tracks...), t)
+		route.Length += t.Geometry.OptionLength(i)
+		if t.Geometry.IsTurnout() {
+			route.Settings = append(append([]Setting{}, r.route.Settings...), Setting{Turnout: t, Option: i})
+		}
+		if hasMark(out, r.to) || (out.Opposite != nil && hasMark(out.Opposite, r.to)) {
+			if len(r.routes) == r.max {
+				r.truncated = true
+				return
+			}
+			r.routes = append(r.routes, &route)
+			continue
+		}
+		if out.Opposite == nil {
+			continue
+		}
+		saved := r.route
+		r.route = &route
+		r.enter(out.Opposite)
+		r.route = saved
+	}
+}
+
+func hasMark(con *tracks.TrackConnection, mark *tracks.TrackMark) bool {
+	for _, m := range con.Marks {
+		if m == mark {
+			return true
+		}
+	}
+	return false
+}
+
+// A track passed by a route with the option selected to pass it.
+// Hops form a linked list from the end to the start of a route.
+type hop struct {
+	track  *tracks.Track
+	option int
+	prev   *hop
+}
+
+// Returns true if the route up to and including this hop passes the track.
+func (h *hop) passes(t *tracks.Track) bool {
+	for ; h != nil; h = h.prev {
+		if h.track == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the route which ends with this hop.
+func (h *hop) route(from *tracks.TrackMark, to *tracks.TrackMark, length float64) *Route {
+	var hops []*hop
+	for ; h != nil; h = h.prev {
+		hops = append(hops, h)
+	}
+	r := &Route{From: from, To: to, Length: length}
+	for i := len(hops) - 1; i >= 0; i-- {
+		t := hops[i].track
+		r.Tracks = append(r.Tracks, t)
+		if t.Geometry.IsTurnout() {
+			r.Settings = append(r.Settings, Setting{Turnout: t, Option: hops[i].option})
+		}
+	}
+	return r
+}
+
+// A train entering a track via a connection after travelling a given length.
+// If the connection is nil, the train has reached the end of the route.
+type step struct {
+	in     *tracks.TrackConnection
+	length float64
+	// The tracks passed so far or nil.
+	prev *hop
+}
+
+// Implements heap.Interface for steps ordered by their length.
+type queue []*step
+
+func (q queue) Len() int            { return len(q) }
+func (q queue) Less(i, j int) bool  { return q[i].length < q[j].length }
+func (q queue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *queue) Push(x interface{}) { *q = append(*q, x.(*step)) }
+
+func (q *queue) Pop() interface{} {
+	old := *q
+	s := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return s
+}
@@ -0,0 +1,141 @@
+package routing_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/weistn/ferrovia/errlog"
+	"github.com/weistn/ferrovia/interpreter"
+	"github.com/weistn/ferrovia/model"
+	"github.com/weistn/ferrovia/model/routing"
+	"github.com/weistn/ferrovia/model/tracks"
+	"github.com/weistn/ferrovia/parser"
+)
+
+const stationData = `tracks {
+	@(0 mm, 0 mm, 0 mm, 90 deg)
+	"main"
+	G1
+	WR10 {
+		right { R10 L10 G1 "track2" }
+	}
+	G1
+	"track1"
+}
+
+tracks {
+	@(0 mm, 1000 mm, 0 mm, 90 deg)
+	"siding"
+	G1
+}
+
+tracks {
+	@(2000 mm, 0 mm, 0 mm, 0 deg)
+	Loop
+	"a"
+	4 * R6
+	"b"
+	7 * R6
+	Loop
+}
+
+tracks Loop {
+	R6
+}
+`
+
+func load(t *testing.T, data string) *model.Model {
+	tracks.InitRoco()
+	e := errlog.NewErrorLog()
+	fileId := e.AddFile(errlog.NewSourceFile("data"))
+	file := parser.NewParser(e).Parse(fileId, data)
+	m := interpreter.NewInterpreter(e).ProcessStatics(file)
+	if e.HasErrors() {
+		t.Fatal(e.ToString())
+	}
+	return m
+}
+
+func TestRoutes(t *testing.T) {
+	m := load(t, stationData)
+	mark := m.Tracks.GetMark
+
+	// Each station track requires its own setting of the turnout
+	for i, name := range []string{"track1", "track2"} {
+		routes, _ := routing.Routes(mark("main"), mark(name), 100)
+		if len(routes) != 1 {
+			t.Fatalf("Expected one route to %v, got %v", name, len(routes))
+		}
+		r := routes[0]
+		if len(r.Settings) != 1 || r.Settings[0].Turnout.Geometry.Name != "WR10" || r.Settings[0].Option != i {
+			t.Fatalf("Wrong turnout settings for %v: %v", name, r.Settings)
+		}
+		r.Set()
+		if r.Settings[0].Turnout.SelectedTurnoutOption != i {
+			t.Fatal("The route has not been set")
+		}
+	}
+	if r := routing.Shortest(mark("main"), mark("track1")); len(r.Tracks) != 3 || math.Abs(r.Length-805) > 0.01 {
+		t.Fatalf("Wrong route to track1: %v tracks, %v mm", len(r.Tracks), r.Length)
+	}
+	if routing.Shortest(mark("main"), mark("siding")) != nil {
+		t.Fatal("The siding is not connected")
+	}
+
+	// A loop can be travelled in both directions
+	routes, _ := routing.Routes(mark("a"), mark("b"), 100)
+	if len(routes) != 2 || len(routes[0].Tracks) != 4 || len(routes[1].Tracks) != 8 {
+		t.Fatalf("Expected routes around the loop in both directions, got %v", len(routes))
+	}
+}
+
+func TestShortest(t *testing.T) {
+	m := load(t, stationData)
+	// The shortest route is the first one of all routes
+	marks := m.Tracks.Marks()
+	for _, from := range marks {
+		for _, to := range marks {
+			routes, _ := routing.Routes(from, to, 100)
+			r := routing.Shortest(from, to)
+			if len(routes) == 0 {
+				if r != nil {
+					t.Fatalf("Unexpected route from %v to %v", from.Name(), to.Name())
+				}
+				continue
+			}
+			if r == nil || math.Abs(r.Length-routes[0].Length) > 0.01 || len(r.Tracks) != len(routes[0].Tracks) || len(r.Settings) != len(routes[0].Settings) {
+				t.Fatalf("Wrong shortest route from %v to %v", from.Name(), to.Name())
+			}
+		}
+	}
+
+	// A ladder of 30 pairs of turnouts, which splits and joins again, has 2^30 routes
+	ts := tracks.NewTrackSystem()
+	l := ts.Layers[""]
+	start := l.NewTrack("G1")
+	start.Connection(0).AddMark("start")
+	last := start.Connection(1)
+	for i := 0; i < 30; i++ {
+		split := l.NewTrack("WR10")
+		join := l.NewTrack("WR10")
+		last.Connect(split.Connection(0))
+		split.Connection(1).Connect(join.Connection(1))
+		split.Connection(2).Connect(join.Connection(2))
+		last = join.Connection(0)
+	}
+	last.AddMark("end")
+	begin := time.Now()
+	r := routing.Shortest(ts.GetMark("start"), ts.GetMark("end"))
+	if r == nil || len(r.Tracks) != 61 || len(r.Settings) != 60 {
+		t.Fatal("Wrong route along the ladder")
+	}
+	if d := time.Since(begin); d > time.Second {
+		t.Fatalf("The shortest route took %v", d)
+	}
+	// Enumerating all routes stops after the maximum number of routes
+	routes, complete := routing.Routes(ts.GetMark("start"), ts.GetMark("end"), 1000)
+	if complete || len(routes) != 1000 {
+		t.Fatalf("Expected the search to stop after 1000 routes, got %v", len(routes))
+	}
+}
@@ -2,6 +2,7 @@ package switchboard
 
 import (
 	"github.com/weistn/ferrovia/errlog"
+	"github.com/weistn/ferrovia/model/tracks"
 )
 
 type ASCIICellConnection int
@@ -83,8 +84,12 @@ type ASCIISwitchboardCell struct {
 	Rune        rune
 	X           int
 	Y           int
-	Text        string
-	Anchor      *ASCIISwitchboardCell
+	// The text of a label, the label of a switch, which names the mark next to its turnout,
+	// or the label of the first cell of a block, which names the block.
+	Text   string
+	Anchor *ASCIISwitchboardCell
+	// The turnout which is operated by a switch or nil.
+	Turnout *tracks.Track
 }
 
 func (l *ASCIISwitchboard) Cell(x int, y int) *ASCIISwitchboardCell {
@@ -94,6 +99,16 @@ func (l *ASCIISwitchboard) Cell(x int, y int) *ASCIISwitchboardCell {
 	return &l.Cells[y*l.ColumnCount+x]
 }
 
+// Returns the switch with the given label or nil.
+func (l *ASCIISwitchboard) Switch(label string) *ASCIISwitchboardCell {
+	for i := range l.Cells {
+		if c := &l.Cells[i]; c.IsSwitch() && c.Text == label {
+			return c
+		}
+	}
+	return nil
+}
+
 func (l *ASCIISwitchboard) CellBelow(x int, y int) *ASCIISwitchboardCell {
 	if y+1 >= l.LineCount {
 		return nil
@@ -122,6 +137,15 @@ func (l *ASCIISwitchboard) CellLeftOf(x int, y int) *ASCIISwitchboardCell {
 	return &l.Cells[y*l.ColumnCount+x-1]
 }
 
+func (c *ASCIISwitchboardCell) IsSwitch() bool {
+	return c.Type >= SwitchVerticalDiagonalUpper && c.Type <= SwitchHorizontalCrossDiagonalBack
+}
+
+// Returns true for all cells of a block.
+func (c *ASCIISwitchboardCell) IsBlock() bool {
+	return c.Type == TrackHorizontalBlock || c.Type == TrackVerticalBlock
+}
+
 func (c *ASCIISwitchboardCell) ConnectsToTop() bool {
 	return c.Connections&ConnectTop == ConnectTop
 }
@@ -0,0 +1,174 @@
+package tracks
+
+import (
+	"math"
+	"sort"
+)
+
+// Name of the catalog used if a layout does not select a track system.
+const DefaultCatalog = "roco-line"
+
+// A Catalog lists all pieces of one track system, e.g. Roco Line or Märklin C.
+// The names of the pieces are only unique within a catalog.
+type Catalog struct {
+	Name      string
+	factories map[string]TrackFactoryFunc
+	// Registers the pieces of the catalog.
+	// It is called when the catalog is used for the first time.
+	load   func(c *Catalog)
+	loaded bool
+	// Optional catalog which provides all pieces not found in this catalog.
+	parent *Catalog
+}
+
+// All registered catalogs by name.
+var catalogs = make(map[string]*Catalog)
+
+// Registers a catalog under a name.
+// The load function registers the pieces of the catalog once the catalog is used for the first time.
+func RegisterCatalog(name string, load func(c *Catalog)) {
+	if _, ok := catalogs[name]; ok {
+		panic("Duplicate catalog name " + name)
+	}
+	catalogs[name] = &Catalog{Name: name, factories: make(map[string]TrackFactoryFunc), load: load}
+}
+
+// Returns a catalog which is not registered and which extends the parent catalog.
+// It holds pieces which are defined by a layout instead of a track system.
+func NewCatalog(name string, parent *Catalog) *Catalog {
+	return &Catalog{Name: name, factories: make(map[string]TrackFactoryFunc), loaded: true, parent: parent}
+}
+
+// Returns the catalog of the given name or nil if no such catalog has been registered.
+func GetCatalog(name string) *Catalog {
+	c, ok := catalogs[name]
+	if !ok {
+		return nil
+	}
+	if !c.loaded {
+		c.loaded = true
+		c.load(c)
+	}
+	return c
+}
+
+// Returns the names of all registered catalogs in alphabetical order.
+func CatalogNames() []string {
+	var names []string
+	for name := range catalogs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Registers a function that can create a track of a certain kind
+func (c *Catalog) RegisterTrackFactory(kind string, fn TrackFactoryFunc) {
+	c.factories[kind] = fn
+}
+
+// Returns the factory for tracks of the given kind.
+func (c *Catalog) TrackFactory(kind string) (TrackFactoryFunc, bool) {
+	fn, ok := c.factories[kind]
+	if !ok && c.parent != nil {
+		return c.parent.TrackFactory(kind)
+	}
+	return fn, ok
+}
+
+// Returns the names of all pieces in alphabetical order.
+func (c *Catalog) Kinds() []string {
+	var kinds []string
+	if c.parent != nil {
+		kinds = c.parent.Kinds()
+	}
+	for kind := range c.factories {
+		if c.parent != nil {
+			if _, ok := c.parent.TrackFactory(kind); ok {
+				continue
+			}
+		}
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Registers a factory which creates tracks of the given geometry.
+// Curves turning left are created by reversing the geometry of a curve turning right.
+func (c *Catalog) registerGeometry(kind string, geo *TrackGeometry, reverse bool) {
+	c.RegisterTrackFactory(kind, func(l *TrackLayer, id int) *Track {
+		return NewTrack(l, id, geo, reverse)
+	})
+}
+
+// Returns the geometry of a straight track.
+func NewStraightGeometry(name string, length float64) *TrackGeometry {
+	return &TrackGeometry{
+		Name: name,
+		Paths: []ITrackGeometryPath{
+			&TrackGeometryLine{Size: length},
+		},
+		ConnectionPoints: []TrackGeometryPoint{
+			{Position: Vec2{0, 0}, Angle: 0},
+			{Position: Vec2{0, length}, Angle: 180},
+		},
+		IncomingConnectionCount: 1,
+		OutgoingConnectionCount: 1,
+		TurnoutOptions:          []TurnoutOption{{From: 0, To: 1}},
+	}
+}
+
+// Returns the geometry of a track turning right.
+// The angle is measured in degree.
+func NewCurveGeometry(name string, radius float64, angle float64) *TrackGeometry {
+	return &TrackGeometry{
+		Name: name,
+		Paths: []ITrackGeometryPath{
+			&TrackGeometryArc{TrackAngle: angle, Radius: radius},
+		},
+		ConnectionPoints: []TrackGeometryPoint{
+			{Position: Vec2{0, 0}, Angle: 0},
+			curveEnd(radius, angle, false),
+		},
+		IncomingConnectionCount: 1,
+		OutgoingConnectionCount: 1,
+		TurnoutOptions:          []TurnoutOption{{From: 0, To: 1}},
+	}
+}
+
+// Returns the geometry of a turnout with a straight track of the given length
+// and a branch turning left or right.
+// As usual, the outgoing connections are sorted from left to right and the straight track is the first turnout option.
+func NewTurnoutGeometry(name string, length float64, radius float64, angle float64, left bool) *TrackGeometry {
+	g := &TrackGeometry{
+		Name:                    name,
+		IncomingConnectionCount: 1,
+		OutgoingConnectionCount: 2,
+	}
+	straight := &TrackGeometryLine{Size: length}
+	end := curveEnd(radius, angle, left)
+	if left {
+		// The branch is drawn from its end towards the start of the turnout
+		a := angle * math.Pi / 180
+		branch := &TrackGeometryArc{TrackAngle: angle, Radius: radius, Anchor: TrackGeometryPoint{Position: Vec2{-radius * (1 - math.Cos(a)), -radius * math.Sin(a)}, Angle: 180 - angle}}
+		g.Paths = []ITrackGeometryPath{straight, branch}
+		g.ConnectionPoints = []TrackGeometryPoint{{}, end, {Position: Vec2{0, length}, Angle: 180}}
+		g.TurnoutOptions = []TurnoutOption{{From: 0, To: 2}, {From: 0, To: 1}}
+	} else {
+		branch := &TrackGeometryArc{TrackAngle: angle, Radius: radius}
+		g.Paths = []ITrackGeometryPath{straight, branch}
+		g.ConnectionPoints = []TrackGeometryPoint{{}, {Position: Vec2{0, length}, Angle: 180}, end}
+		g.TurnoutOptions = []TurnoutOption{{From: 0, To: 1}, {From: 0, To: 2}}
+	}
+	return g
+}
+
+// Returns the connection point at the end of an arc starting at the origin.
+func curveEnd(radius float64, angle float64, left bool) TrackGeometryPoint {
+	a := angle * math.Pi / 180
+	if left {
+		return TrackGeometryPoint{Position: Vec2{radius * (1 - math.Cos(a)), radius * math.Sin(a)}, Angle: 180 - angle}
+	}
+	return TrackGeometryPoint{Position: Vec2{-radius * (1 - math.Cos(a)), radius * math.Sin(a)}, Angle: 180 + angle}
+}
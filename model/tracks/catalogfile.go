@@ -0,0 +1,250 @@
+package tracks
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// The content of a catalog file.
+//
+// A catalog file is a JSON document listing all pieces of a track system.
+// Simple pieces are described by their dimensions, i.e. as straight, curve or turnout.
+// All other pieces describe their geometry in terms of paths and connection points, just like TrackGeometry.
+// Lengths are measured in mm and angles in degree.
+type CatalogFile struct {
+	// Name of the track system, as used by the system directive.
+	Name   string          `json:"name"`
+	Pieces []*CatalogPiece `json:"pieces"`
+}
+
+// A piece in a CatalogFile.
+// Exactly one of Straight, Curve, Turnout or Geometry must be set.
+type CatalogPiece struct {
+	// Name of the geometry, e.g. as shown in the bill of materials.
+	Name string `json:"name"`
+	// Name of the piece in a tracks block. Defaults to Name.
+	Kind string `json:"kind,omitempty"`
+	// Optional name of the piece when it is laid in reverse direction,
+	// e.g. L6 for the curve R6 turning left.
+	Reversed string `json:"reversed,omitempty"`
+	// True if the piece is not sold by the vendor, e.g. a curve cut from flexible track.
+	Custom   bool             `json:"custom,omitempty"`
+	Straight *CatalogStraight `json:"straight,omitempty"`
+	Curve    *CatalogCurve    `json:"curve,omitempty"`
+	Turnout  *CatalogTurnout  `json:"turnout,omitempty"`
+	Geometry *CatalogGeometry `json:"geometry,omitempty"`
+}
+
+type CatalogStraight struct {
+	Length float64 `json:"length"`
+}
+
+// A curve turning right.
+type CatalogCurve struct {
+	Radius float64 `json:"radius"`
+	Angle  float64 `json:"angle"`
+}
+
+// A turnout with a straight track and one branch.
+type CatalogTurnout struct {
+	Length float64 `json:"length"`
+	Radius float64 `json:"radius"`
+	Angle  float64 `json:"angle"`
+	// Either "left" or "right".
+	Branch string `json:"branch"`
+}
+
+// Mirrors TrackGeometry.
+type CatalogGeometry struct {
+	Paths       []*CatalogPath `json:"paths"`
+	Connections []CatalogPoint `json:"connections"`
+	Incoming    int            `json:"incoming"`
+	Outgoing    int            `json:"outgoing"`
+	// Pairs of incoming and outgoing connections as in TurnoutOption.
+	Options [][2]int `json:"options"`
+}
+
+// Either a line (Length is set) or an arc (Radius and Angle are set).
+type CatalogPath struct {
+	Length float64      `json:"length,omitempty"`
+	Radius float64      `json:"radius,omitempty"`
+	Angle  float64      `json:"angle,omitempty"`
+	Anchor CatalogPoint `json:"anchor"`
+}
+
+// Mirrors TrackGeometryPoint.
+type CatalogPoint struct {
+	X     float64 `json:"x"`
+	Y     float64 `json:"y"`
+	Angle float64 `json:"angle"`
+}
+
+// Parses and validates a catalog file and registers the catalog.
+// The pieces are registered when the catalog is used for the first time.
+func RegisterCatalogFile(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	f := &CatalogFile{}
+	if err := dec.Decode(f); err != nil {
+		return err
+	}
+	geometries, err := f.geometries()
+	if err != nil {
+		return err
+	}
+	if _, ok := catalogs[f.Name]; ok {
+		return fmt.Errorf("catalog %v: a track system of the same name already exists", f.Name)
+	}
+	RegisterCatalog(f.Name, func(c *Catalog) {
+		for i, p := range f.Pieces {
+			c.registerPiece(p, geometries[i])
+		}
+	})
+	return nil
+}
+
+// Validates a piece and registers it in the catalog.
+func (c *Catalog) RegisterPiece(p *CatalogPiece) error {
+	g, err := p.TrackGeometry()
+	if err != nil {
+		return err
+	}
+	if p.Reversed == p.kind() {
+		return fmt.Errorf("the piece %v and its reversed piece have the same name", p.Name)
+	}
+	for _, kind := range []string{p.kind(), p.Reversed} {
+		if _, ok := c.TrackFactory(kind); ok {
+			return fmt.Errorf("a piece named %v already exists", kind)
+		}
+	}
+	c.registerPiece(p, g)
+	return nil
+}
+
+func (c *Catalog) registerPiece(p *CatalogPiece, g *TrackGeometry) {
+	c.registerGeometry(p.kind(), g, false)
+	if p.Reversed != "" {
+		c.registerGeometry(p.Reversed, g, true)
+	}
+}
+
+// Validates all pieces and returns their geometries.
+func (f *CatalogFile) geometries() ([]*TrackGeometry, error) {
+	if f.Name == "" {
+		return nil, errors.New("catalog without a name")
+	}
+	var result []*TrackGeometry
+	kinds := make(map[string]bool)
+	for i, p := range f.Pieces {
+		if p.Name == "" {
+			return nil, fmt.Errorf("catalog %v: piece %v has no name", f.Name, i+1)
+		}
+		for _, kind := range []string{p.kind(), p.Reversed} {
+			if kind == "" {
+				continue
+			}
+			if kinds[kind] {
+				return nil, fmt.Errorf("catalog %v: piece %v is defined twice", f.Name, kind)
+			}
+			kinds[kind] = true
+		}
+		g, err := p.TrackGeometry()
+		if err != nil {
+			return nil, fmt.Errorf("catalog %v: piece %v: %v", f.Name, p.Name, err)
+		}
+		result = append(result, g)
+	}
+	return result, nil
+}
+
+func (p *CatalogPiece) kind() string {
+	if p.Kind != "" {
+		return p.Kind
+	}
+	return p.Name
+}
+
+// Validates the piece and returns its geometry.
+func (p *CatalogPiece) TrackGeometry() (*TrackGeometry, error) {
+	count := 0
+	for _, set := range []bool{p.Straight != nil, p.Curve != nil, p.Turnout != nil, p.Geometry != nil} {
+		if set {
+			count++
+		}
+	}
+	if count != 1 {
+		return nil, errors.New("exactly one of straight, curve, turnout or geometry is required")
+	}
+	var g *TrackGeometry
+	switch {
+	case p.Straight != nil:
+		if p.Straight.Length <= 0 {
+			return nil, errors.New("the length must be positive")
+		}
+		g = NewStraightGeometry(p.Name, p.Straight.Length)
+	case p.Curve != nil:
+		if p.Curve.Radius <= 0 || p.Curve.Angle <= 0 {
+			return nil, errors.New("radius and angle must be positive")
+		}
+		g = NewCurveGeometry(p.Name, p.Curve.Radius, p.Curve.Angle)
+	case p.Turnout != nil:
+		t := p.Turnout
+		if t.Length <= 0 || t.Radius <= 0 || t.Angle <= 0 {
+			return nil, errors.New("length, radius and angle must be positive")
+		}
+		if t.Branch != "left" && t.Branch != "right" {
+			return nil, errors.New("the branch must be left or right")
+		}
+		g = NewTurnoutGeometry(p.Name, t.Length, t.Radius, t.Angle, t.Branch == "left")
+	default:
+		var err error
+		if g, err = p.Geometry.toGeometry(p.Name); err != nil {
+			return nil, err
+		}
+	}
+	g.Custom = p.Custom
+	return g, nil
+}
+
+func (cg *CatalogGeometry) toGeometry(name string) (*TrackGeometry, error) {
+	if len(cg.Paths) == 0 {
+		return nil, errors.New("the geometry has no paths")
+	}
+	if cg.Incoming < 1 || cg.Outgoing < 1 {
+		return nil, errors.New("at least one incoming and one outgoing connection are required")
+	}
+	if cg.Incoming+cg.Outgoing != len(cg.Connections) {
+		return nil, errors.New("the number of incoming and outgoing connections does not match the connections")
+	}
+	if len(cg.Options) == 0 {
+		return nil, errors.New("the geometry has no options")
+	}
+	g := &TrackGeometry{Name: name, IncomingConnectionCount: cg.Incoming, OutgoingConnectionCount: cg.Outgoing}
+	for _, p := range cg.Paths {
+		anchor := p.Anchor.toPoint()
+		switch {
+		case p.Length > 0 && p.Radius == 0 && p.Angle == 0:
+			g.Paths = append(g.Paths, &TrackGeometryLine{Size: p.Length, Anchor: anchor})
+		case p.Length == 0 && p.Radius > 0 && p.Angle > 0:
+			g.Paths = append(g.Paths, &TrackGeometryArc{TrackAngle: p.Angle, Radius: p.Radius, Anchor: anchor})
+		default:
+			return nil, errors.New("a path must either have a length or a radius and an angle")
+		}
+	}
+	for _, c := range cg.Connections {
+		g.ConnectionPoints = append(g.ConnectionPoints, c.toPoint())
+	}
+	for _, o := range cg.Options {
+		if o[0] < 0 || o[0] >= cg.Incoming || o[1] < cg.Incoming || o[1] >= len(cg.Connections) {
+			return nil, fmt.Errorf("the option %v-%v must lead from an incoming to an outgoing connection", o[0], o[1])
+		}
+		g.TurnoutOptions = append(g.TurnoutOptions, TurnoutOption{From: o[0], To: o[1]})
+	}
+	return g, nil
+}
+
+func (p CatalogPoint) toPoint() TrackGeometryPoint {
+	return TrackGeometryPoint{Position: Vec2{p.X, p.Y}, Angle: p.Angle}
+}
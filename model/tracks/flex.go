@@ -0,0 +1,167 @@
+package tracks
+
+import "math"
+
+// The smallest radius of flexible track unless a layout demands otherwise.
+// It equals the radius of the tightest Roco Line curve R2.
+const DefaultFlexRadius = 358
+
+// Flexible track is laid out with the largest radius that makes it at most this much longer
+// than the shortest possible shape.
+const flexLengthTolerance = 1.05
+
+// Name of the geometry of flexible tracks.
+const FlexName = "flex"
+
+// Returns the geometry of flexible track before its shape is known.
+// It has two connections, but no paths.
+func NewFlexPlaceholder() *TrackGeometry {
+	return &TrackGeometry{
+		Name:                    FlexName,
+		ConnectionPoints:        []TrackGeometryPoint{{}, {Angle: 180}},
+		IncomingConnectionCount: 1,
+		OutgoingConnectionCount: 1,
+		TurnoutOptions:          []TurnoutOption{{From: 0, To: 1}},
+		Custom:                  true,
+	}
+}
+
+// One curve or straight of a flex track in world coordinates.
+// Angles are measured in radians like in math, i.e. counter clock-wise from the x-axis.
+type flexSegment struct {
+	from  Vec2
+	angle float64
+	// Zero for a straight track. For curves +1 if the angle increases along the curve and -1 otherwise.
+	turn float64
+	// Radius of curves.
+	radius float64
+	// Length of a straight or the angle of a curve.
+	size float64
+}
+
+// A curve, a straight and another curve in this order.
+// Curves and the straight may have size zero.
+type flexShape struct {
+	segments [3]flexSegment
+	length   float64
+}
+
+// Computes the geometry of a flexible track which starts at `from` heading in direction fromAngle
+// and ends at `to` heading in direction toAngle. Angles are measured in degree as in TrackLocation.
+// Connection 0 of the geometry is the start. The function returns false if no shape exists
+// whose curves have at least the minimum radius and turn by at most 180 degree each.
+func NewFlexGeometry(from Vec2, fromAngle float64, to Vec2, toAngle float64, minRadius float64) (*TrackGeometry, bool) {
+	// In TrackLocation, an angle of zero means heading along the negative y-axis
+	a0 := (fromAngle - 90) * math.Pi / 180
+	a1 := (toAngle - 90) * math.Pi / 180
+	best, ok := shortestFlexShape(from, a0, to, a1, minRadius)
+	if !ok {
+		return nil, false
+	}
+	// Try ever larger radii as long as the track does not become much longer
+	maxLength := best.length * flexLengthTolerance
+	dist := math.Hypot(to[0]-from[0], to[1]-from[1])
+	for r := minRadius * 1.25; r < 10*dist; r *= 1.25 {
+		if s, ok := shortestFlexShape(from, a0, to, a1, r); ok && s.length <= maxLength {
+			best = s
+		}
+	}
+	return best.geometry(from, fromAngle, to, toAngle), true
+}
+
+// Returns the shortest shape made of two curves of the given radius and a straight in between.
+func shortestFlexShape(from Vec2, a0 float64, to Vec2, a1 float64, radius float64) (flexShape, bool) {
+	var best flexShape
+	found := false
+	for _, turn0 := range []float64{1, -1} {
+		for _, turn1 := range []float64{1, -1} {
+			s, ok := newFlexShape(from, a0, to, a1, radius, turn0, turn1)
+			if ok && (!found || s.length < best.length) {
+				best = s
+				found = true
+			}
+		}
+	}
+	return best, found
+}
+
+// Computes the shape with curves turning in the given directions, if it exists.
+// This is the CSC family of Dubins paths.
+func newFlexShape(from Vec2, a0 float64, to Vec2, a1 float64, radius float64, turn0 float64, turn1 float64) (flexShape, bool) {
+	c0 := Vec2{from[0] - turn0*radius*math.Sin(a0), from[1] + turn0*radius*math.Cos(a0)}
+	c1 := Vec2{to[0] - turn1*radius*math.Sin(a1), to[1] + turn1*radius*math.Cos(a1)}
+	dx, dy := c1[0]-c0[0], c1[1]-c0[1]
+	d := math.Hypot(dx, dy)
+	// Direction and length of the straight
+	angle := math.Atan2(dy, dx)
+	if d < 1e-6 {
+		// Both curves lie on the same circle, hence the straight has no direction of its own
+		angle = a0
+	}
+	length := d
+	if turn0 != turn1 {
+		// The straight is a tangent crossing between the two circles
+		if d < 2*radius {
+			return flexShape{}, false
+		}
+		length = math.Sqrt(d*d - 4*radius*radius)
+		angle += math.Atan2(2*turn0*radius, length)
+	}
+	curve0 := normalizeRadians(turn0 * (angle - a0))
+	curve1 := normalizeRadians(turn1 * (a1 - angle))
+	if curve0 > math.Pi+1e-9 || curve1 > math.Pi+1e-9 {
+		return flexShape{}, false
+	}
+	// Start of the straight
+	p := Vec2{c0[0] + turn0*radius*math.Sin(angle), c0[1] - turn0*radius*math.Cos(angle)}
+	s := flexShape{length: radius*(curve0+curve1) + length}
+	s.segments[0] = flexSegment{from: from, angle: a0, turn: turn0, radius: radius, size: curve0}
+	s.segments[1] = flexSegment{from: p, angle: angle, size: length}
+	s.segments[2] = flexSegment{from: Vec2{p[0] + length*math.Cos(angle), p[1] + length*math.Sin(angle)}, angle: angle, turn: turn1, radius: radius, size: curve1}
+	return s, true
+}
+
+// Returns an angle in the range [0,2*Pi[.
+func normalizeRadians(a float64) float64 {
+	a = math.Mod(a, 2*math.Pi)
+	if a < 0 {
+		a += 2 * math.Pi
+	}
+	// Avoid almost full circles due to rounding errors
+	if a > 2*math.Pi-1e-9 {
+		a = 0
+	}
+	return a
+}
+
+// Converts the shape into a geometry whose connection 0 is located at `from`.
+func (s *flexShape) geometry(from Vec2, fromAngle float64, to Vec2, toAngle float64) *TrackGeometry {
+	g := NewFlexPlaceholder()
+	// Converts world coordinates into coordinates relative to the start of the track
+	local := func(p Vec2) Vec2 {
+		return Vec2{p[0] - from[0], p[1] - from[1]}.Rotate(-fromAngle)
+	}
+	for _, seg := range s.segments {
+		if seg.size < 1e-6 {
+			continue
+		}
+		// Angle in degree relative to the start of the track
+		angle := seg.angle*180/math.Pi + 90 - fromAngle
+		if seg.turn == 0 {
+			g.Paths = append(g.Paths, &TrackGeometryLine{Size: seg.size, Anchor: TrackGeometryPoint{Position: local(seg.from), Angle: angle}})
+			continue
+		}
+		trackAngle := seg.size * 180 / math.Pi
+		if seg.turn > 0 {
+			g.Paths = append(g.Paths, &TrackGeometryArc{Radius: seg.radius, TrackAngle: trackAngle, Anchor: TrackGeometryPoint{Position: local(seg.from), Angle: angle}})
+			continue
+		}
+		// Arcs always turn clock-wise. Hence, the arc is drawn from its end towards its start
+		c := Vec2{seg.from[0] + seg.radius*math.Sin(seg.angle), seg.from[1] - seg.radius*math.Cos(seg.angle)}
+		end := seg.angle - seg.size
+		p := Vec2{c[0] - seg.radius*math.Sin(end), c[1] + seg.radius*math.Cos(end)}
+		g.Paths = append(g.Paths, &TrackGeometryArc{Radius: seg.radius, TrackAngle: trackAngle, Anchor: TrackGeometryPoint{Position: local(p), Angle: angle - trackAngle + 180}})
+	}
+	g.ConnectionPoints[1] = TrackGeometryPoint{Position: local(to).Invert(), Angle: toAngle - fromAngle - 180}
+	return g
+}
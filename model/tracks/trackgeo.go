@@ -1,5 +1,7 @@
 package tracks
 
+import "math"
+
 // Tracks of the same kind all share the same track geometry.
 type TrackGeometry struct {
 	Name string
@@ -19,6 +21,127 @@ type TrackGeometry struct {
 	// sorted clock-wise.
 	IncomingConnectionCount int
 	OutgoingConnectionCount int
+	// True if the geometry does not describe a piece from the catalogue of the vendor,
+	// e.g. a curve which must be cut from flexible track.
+	Custom bool
+}
+
+// Returns the length in mm of the geometry.
+// For turnouts this is the first route listed by the geometry, i.e. the first path.
+// Other tracks, e.g. flexible tracks, may consist of several paths which add up.
+func (g *TrackGeometry) Length() float64 {
+	paths := g.Paths
+	if len(g.ConnectionPoints) > 2 && len(paths) > 1 {
+		paths = paths[:1]
+	}
+	length := 0.0
+	for _, path := range paths {
+		length += pathLength(path)
+	}
+	return length
+}
+
+// Returns the length in mm of the route selected by a turnout option.
+// Turnouts usually list one path per option. Otherwise the route is assumed to be a circular arc
+// between the two connection points.
+func (g *TrackGeometry) OptionLength(index int) float64 {
+	if len(g.TurnoutOptions) == 1 {
+		return g.Length()
+	}
+	if path := g.OptionPath(index); path != nil {
+		return pathLength(path)
+	}
+	from := g.ConnectionPoints[g.TurnoutOptions[index].From]
+	to := g.ConnectionPoints[g.TurnoutOptions[index].To]
+	chord := math.Hypot(from.Position[0]-to.Position[0], from.Position[1]-to.Position[1])
+	// Half of the change in direction
+	a := math.Abs(math.Remainder(to.Angle-from.Angle-180, 360)) * math.Pi / 360
+	if a < 1e-6 {
+		return chord
+	}
+	return chord * a / math.Sin(a)
+}
+
+// Returns the path which joins the two connection points of a turnout option or nil.
+// Paths are not listed in the order of the options, e.g. the straight path of a turnout is
+// always listed first, while the options are sorted by their connection points.
+func (g *TrackGeometry) OptionPath(index int) ITrackGeometryPath {
+	// Paths use the coordinates of connection points with inverted positions
+	from := g.ConnectionPoints[g.TurnoutOptions[index].From].Position.Invert()
+	to := g.ConnectionPoints[g.TurnoutOptions[index].To].Position.Invert()
+	for _, path := range g.Paths {
+		start, end := pathEnds(path)
+		if (near(start, from) && near(end, to)) || (near(start, to) && near(end, from)) {
+			return path
+		}
+	}
+	return nil
+}
+
+// Returns the start and the end of a path relative to the center of the track.
+func pathEnds(path ITrackGeometryPath) (start Vec2, end Vec2) {
+	switch p := path.(type) {
+	case *TrackGeometryLine:
+		start = p.Anchor.Position
+		d := Vec2{0, -p.Size}.Rotate(p.Anchor.Angle)
+		end = Vec2{start[0] + d[0], start[1] + d[1]}
+	case *TrackGeometryArc:
+		start = p.Anchor.Position
+		a := p.Anchor.Angle * math.Pi / 180
+		cx := start[0] + math.Cos(a)*p.Radius
+		cy := start[1] + math.Sin(a)*p.Radius
+		phi := a + math.Pi + p.TrackAngle*math.Pi/180
+		end = Vec2{cx + math.Cos(phi)*p.Radius, cy + math.Sin(phi)*p.Radius}
+	}
+	return
+}
+
+// Returns true if two points are less than a millimeter apart.
+func near(a Vec2, b Vec2) bool {
+	return math.Hypot(a[0]-b[0], a[1]-b[1]) < 1
+}
+
+func pathLength(path ITrackGeometryPath) float64 {
+	switch p := path.(type) {
+	case *TrackGeometryLine:
+		return p.Size
+	case *TrackGeometryArc:
+		return 2 * math.Pi * p.Radius * p.TrackAngle / 360
+	}
+	return 0
+}
+
+// Returns true if a turnout option leads trains onto a curved route.
+func (g *TrackGeometry) IsDiverging(index int) bool {
+	option := g.TurnoutOptions[index]
+	from := g.ConnectionPoints[option.From].Angle
+	to := g.ConnectionPoints[option.To].Angle
+	// Both ends of a straight route face in opposite directions
+	delta := math.Mod(math.Abs(to-from-180), 360)
+	return delta > 0.01 && delta < 359.99
+}
+
+// Returns true if trains entering the track via one connection can leave it via different connections,
+// depending on the selected turnout option.
+func (g *TrackGeometry) IsTurnout() bool {
+	from := make(map[int]bool)
+	for _, o := range g.TurnoutOptions {
+		if from[o.From] {
+			return true
+		}
+		from[o.From] = true
+	}
+	return false
+}
+
+// Returns the height of a connection point relative to the center of the track.
+// Incoming connections are at the lower end of an incline, outgoing connections at the upper end.
+func (g *TrackGeometry) connectionHeight(index int, incline float64) float64 {
+	h := incline / 100 * g.Length() / 2
+	if index < g.IncomingConnectionCount {
+		return -h
+	}
+	return h
 }
 
 // A turnout can allow the train to drive
@@ -67,17 +190,22 @@ type TrackLocation struct {
 	// Angle in degree. Zero means the track is aligned with the x-axis
 	// and is heading to the right side.
 	Rotation float64
-	// Incline in percent of the track length
+	// Incline in percent of the track length.
+	// A positive incline means that the track rises from its incoming to its outgoing connections.
 	Incline float64
 }
 
+// Computes the location of a track such that the connection con is located at pos.
+// The incline is taken from the track.
 func NewTrackLocation(con *TrackConnection, pos Vec3, angle float64) *TrackLocation {
-	p := &con.Track.Geometry.ConnectionPoints[con.Track.ConnectionIndex(con)]
+	index := con.Track.ConnectionIndex(con)
+	p := &con.Track.Geometry.ConnectionPoints[index]
 	r := angle - p.Angle
 	c := p.Position.Rotate(r)
-	center := [3]float64{pos[0] + c[0], pos[1] + c[1], pos[2]}
+	incline := con.Track.geometryIncline()
+	center := [3]float64{pos[0] + c[0], pos[1] + c[1], pos[2] - con.Track.Geometry.connectionHeight(index, incline)}
 	rotation := normalizeAngle(r)
-	return &TrackLocation{Center: center, Rotation: rotation}
+	return &TrackLocation{Center: center, Rotation: rotation, Incline: incline}
 }
 
 // Given a track location and a track geometry, the function returns the
@@ -86,5 +214,6 @@ func (l *TrackLocation) Connection(index int, geometry *TrackGeometry) (pos Vec3
 	c := &geometry.ConnectionPoints[index]
 	angle = normalizeAngle(l.Rotation + c.Angle + 180)
 	pos = l.Center.Add2(c.Position.Invert().Rotate(l.Rotation))
+	pos[2] += geometry.connectionHeight(index, l.Incline)
 	return
 }
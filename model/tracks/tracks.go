@@ -1,13 +1,17 @@
 package tracks
 
 import (
+	"sort"
+
 	"github.com/weistn/ferrovia/errlog"
 )
 
 // A TrackSystem connsist of tracks, which are usually connected with each other (but not necessarily).
 type TrackSystem struct {
 	Layers map[string]*TrackLayer
-	marks  map[string]*TrackMark
+	// The catalog from which tracks are created.
+	Catalog *Catalog
+	marks   map[string]*TrackMark
 }
 
 type TrackLayer struct {
@@ -34,6 +38,12 @@ type Track struct {
 	// The index of the currently selected option.
 	SelectedTurnoutOption int
 	SourceLocation        errlog.LocationRange
+	// Name of the named tracks block in which the track has been defined.
+	// The empty string for tracks defined in anonymous tracks blocks.
+	Group string
+	// Incline in percent in the direction from the first to the second connection.
+	// It must be set before the location of the track is computed.
+	Incline float64
 }
 
 // Each track has multiple connection points, each represented by TrackConnection.
@@ -70,22 +80,14 @@ const (
 	JunctionCross  = "K-"
 )
 
-// A map of all registered track factories.
-var TrackFactories map[string]TrackFactoryFunc = make(map[string]TrackFactoryFunc)
-
 // Global variable used by NewEpoch.
 var epoch int
 
 // Global variable used by NewTrack.
 var trackId int
 
-// Registers a function that can create a track of a certain kind
-func RegisterTrackFactory(kind string, fn TrackFactoryFunc) {
-	TrackFactories[kind] = fn
-}
-
 func NewTrackSystem() *TrackSystem {
-	ts := &TrackSystem{marks: make(map[string]*TrackMark), Layers: make(map[string]*TrackLayer)}
+	ts := &TrackSystem{marks: make(map[string]*TrackMark), Layers: make(map[string]*TrackLayer), Catalog: GetCatalog(DefaultCatalog)}
 	ts.AddLayer(&TrackLayer{Name: ""})
 	return ts
 }
@@ -94,6 +96,7 @@ func (ts *TrackSystem) AddLayer(l *TrackLayer) {
 	if _, ok := ts.Layers[l.Name]; ok {
 		panic("Duplicate layer name")
 	}
+	l.TrackSystem = ts
 	ts.Layers[l.Name] = l
 }
 
@@ -105,10 +108,27 @@ func (ts *TrackSystem) GetMark(name string) *TrackMark {
 	return nil
 }
 
+// Returns all named marks sorted by name.
+func (ts *TrackSystem) Marks() []*TrackMark {
+	var result []*TrackMark
+	for _, m := range ts.marks {
+		result = append(result, m)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].name < result[j].name
+	})
+	return result
+}
+
 // Creates a track of the given kind.
-// Returns nil if no corresponding factory has been registered.
+// Returns nil if the catalog of the track system has no such kind of track.
+// Layers that have not been added to a track system use the default catalog.
 func (l *TrackLayer) NewTrack(kind string) *Track {
-	fn, ok := TrackFactories[kind]
+	c := GetCatalog(DefaultCatalog)
+	if l.TrackSystem != nil {
+		c = l.TrackSystem.Catalog
+	}
+	fn, ok := c.TrackFactory(kind)
 	if !ok {
 		return nil
 	}
@@ -132,16 +152,38 @@ func (m *TrackMark) Position() float32 {
 	return m.position
 }
 
-func (c *TrackConnection) Connect(c2 *TrackConnection) {
+// Returns the turnout whose connection carries the mark or is connected to the connection carrying the mark.
+// Returns nil if there is no such turnout or if there are two of them.
+func (m *TrackMark) Turnout() *Track {
+	var result *Track
+	candidates := []*Track{m.track}
+	if m.Connection != nil && m.Connection.Opposite != nil {
+		candidates = append(candidates, m.Connection.Opposite.Track)
+	}
+	for _, t := range candidates {
+		if !t.Geometry.IsTurnout() {
+			continue
+		}
+		if result != nil {
+			return nil
+		}
+		result = t
+	}
+	return result
+}
+
+// Returns false if one of the connections is already connected to another track.
+func (c *TrackConnection) Connect(c2 *TrackConnection) bool {
 	// println("CONNECT", c.Track.Geometry.Name, c.Track.ConnectionIndex(c), c2.Track.Geometry.Name, c2.Track.ConnectionIndex(c2))
 	if c.Opposite != nil && c.Opposite != c2 {
-		panic("Already connected")
+		return false
 	}
 	if c2.Opposite != nil && c2.Opposite != c {
-		panic("Already connected")
+		return false
 	}
 	c.Opposite = c2
 	c2.Opposite = c
+	return true
 }
 
 func (c *TrackConnection) IsConnected() bool {
@@ -200,6 +242,23 @@ func (t *Track) SetLocation(l *TrackLocation) bool {
 	return true
 }
 
+// Applies a changed Incline to the location of the track.
+// The height of the connection con remains unchanged.
+func (t *Track) UpdateIncline(con *TrackConnection) {
+	index := t.ConnectionIndex(con)
+	pos, _ := t.Location.Connection(index, t.Geometry)
+	t.Location.Incline = t.geometryIncline()
+	t.Location.Center[2] = pos[2] - t.Geometry.connectionHeight(index, t.Location.Incline)
+}
+
+// Returns the incline relative to the orientation of the track geometry.
+func (t *Track) geometryIncline() float64 {
+	if t.connectReverse {
+		return -t.Incline
+	}
+	return t.Incline
+}
+
 // At each point in time, a track reaches from one connection to another.
 // Turnouts can change the reachable connection by switching.
 // Thus, a track can have 2, 3, 4 or more connections, but only two are reachable at any time.
@@ -207,6 +266,16 @@ func (t *Track) ActiveConnections() (*TrackConnection, *TrackConnection) {
 	return t.connections[t.Geometry.TurnoutOptions[t.SelectedTurnoutOption].From], t.connections[t.Geometry.TurnoutOptions[t.SelectedTurnoutOption].To]
 }
 
+// Selects the next turnout option. Turnouts with three or more options cycle through all of them.
+func (t *Track) Switch() {
+	t.SelectedTurnoutOption = (t.SelectedTurnoutOption + 1) % len(t.Geometry.TurnoutOptions)
+}
+
+// Returns true if the selected turnout option leads trains onto a curved route.
+func (t *Track) IsDiverging() bool {
+	return t.Geometry.IsDiverging(t.SelectedTurnoutOption)
+}
+
 func (t *Track) Reverse() {
 	t.connectReverse = true
 }
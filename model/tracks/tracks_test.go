@@ -1,6 +1,7 @@
 package tracks
 
 import (
+	"math"
 	"testing"
 )
 
@@ -38,3 +39,58 @@ func TestTrackConstruction2(t *testing.T) {
 	track2.AddMark(1, "end")
 	track.Connect(track2)
 }
+
+func TestNewTrackWithoutTrackSystem(t *testing.T) {
+	l := &TrackLayer{Name: "standalone"}
+	if track := l.NewTrack("G1"); track == nil {
+		t.Fatal("Unknown track type")
+	}
+}
+
+func TestCatalogFile(t *testing.T) {
+	data := `{
+	"name": "test-catalog",
+	"pieces": [
+		{"name": "S100", "straight": {"length": 100}},
+		{"name": "R500", "reversed": "L500", "curve": {"radius": 500, "angle": 30}}
+	]
+}`
+	if err := RegisterCatalogFile([]byte(data)); err != nil {
+		t.Fatal(err)
+	}
+	c := GetCatalog("test-catalog")
+	if kinds := c.Kinds(); len(kinds) != 3 {
+		t.Fatalf("Unexpected pieces %v", kinds)
+	}
+	if err := RegisterCatalogFile([]byte(data)); err == nil {
+		t.Fatal("Expected an error for the duplicate catalog")
+	}
+
+	invalid := []string{
+		`{"name": "invalid", "pieces": [{"name": "S", "straight": {"length": 0}}]}`,
+		`{"name": "invalid", "pieces": [{"name": "S", "straight": {"length": 10}, "curve": {"radius": 10, "angle": 10}}]}`,
+		`{"name": "invalid", "pieces": [{"name": "S", "straight": {"length": 10}}, {"name": "S", "straight": {"length": 20}}]}`,
+		`{"name": "invalid", "pieces": [{"name": "W", "turnout": {"length": 10, "radius": 10, "angle": 10, "branch": "up"}}]}`,
+		`{"name": "invalid", "pieces": [{"name": "X", "geometry": {"paths": [{"length": 10, "anchor": {}}], "connections": [{}, {}], "incoming": 1, "outgoing": 1, "options": [[0, 2]]}}]}`,
+		`{"name": "invalid", "pieces": [{"name": "S", "straight": {"size": 10}}]}`,
+	}
+	for _, data := range invalid {
+		if err := RegisterCatalogFile([]byte(data)); err == nil {
+			t.Fatalf("Expected an error for %v", data)
+		}
+	}
+}
+
+func TestOptionLength(t *testing.T) {
+	InitRoco()
+	l := NewTrackSystem().Layers[""]
+	// The straight path is listed first, although the branch is the first option of left turnouts
+	for kind, lengths := range map[string][]float64{"WL10": {339.64, 345}, "WR10": {345, 339.64}, "DW15": {230, 232.48, 232.48}} {
+		g := l.NewTrack(kind).Geometry
+		for i, length := range lengths {
+			if g.OptionPath(i) == nil || math.Abs(g.OptionLength(i)-length) > 0.01 {
+				t.Fatalf("Wrong length %v of option %v of %v", g.OptionLength(i), i, kind)
+			}
+		}
+	}
+}
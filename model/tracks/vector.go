@@ -40,3 +40,11 @@ func normalizeAngle(a float64) float64 {
 	}
 	return a
 }
+
+func (v Vec3) Sub(v2 Vec3) Vec3 {
+	return [3]float64{v[0] - v2[0], v[1] - v2[1], v[2] - v2[2]}
+}
+
+func (v Vec3) Length() float64 {
+	return math.Sqrt(v[0]*v[0] + v[1]*v[1] + v[2]*v[2])
+}
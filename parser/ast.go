@@ -18,6 +18,43 @@ type GroundPlate struct {
 	Location    errlog.LocationRange
 }
 
+// Implements IDirective
+// Declares design rules, e.g. `rules { minRadius(420 mm) }`.
+type Rules struct {
+	Expressions []IExpression
+	Location    errlog.LocationRange
+}
+
+// Implements IDirective
+// Assigns the addresses of accessory decoders, e.g. `decoders { turnout("W1", 12) }`.
+type Decoders struct {
+	Expressions []IExpression
+	Location    errlog.LocationRange
+}
+
+// Implements IDirective
+// Selects the catalog of track pieces, e.g. `system "maerklin-c"`.
+type System struct {
+	Name     *Token
+	Location errlog.LocationRange
+}
+
+// Implements IDirective
+// Defines a piece of track, e.g. `geometry G75 { straight(75 mm) }`.
+type Geometry struct {
+	Name        *Token
+	Expressions []IExpression
+	Location    errlog.LocationRange
+}
+
+// Implements IDirective
+// Defines a block between two marks, e.g. `block B1 { from("a") to("b") }`.
+type Block struct {
+	Name        *Token
+	Expressions []IExpression
+	Location    errlog.LocationRange
+}
+
 // Implements IDirective
 type Switchboard struct {
 	Name          *Token
@@ -36,7 +73,9 @@ type Layer struct {
 // Implements IDirective
 type Tracks struct {
 	// Optional
-	Name        *Token
+	Name *Token
+	// Nil if the tracks have no parameter list.
+	// Named tracks with a parameter list are templates, which can be used many times.
 	Parameters  []*Parameter
 	Expressions []IExpression
 	Location    errlog.LocationRange
@@ -56,6 +95,12 @@ type BinaryExpression struct {
 	Right IExpression
 }
 
+// Implements IExpression
+type UnaryExpression struct {
+	Op      *Token
+	Operand IExpression
+}
+
 // Implements IExpression
 type IdentifierExpression struct {
 	Identifier *Token
@@ -76,6 +121,8 @@ type DimensionExpression struct {
 type CallExpression struct {
 	Func      IExpression
 	Arguments []IExpression
+	// Location of the entire call including the closing parenthesis
+	Location errlog.LocationRange
 }
 
 // Implements IExpression
@@ -92,6 +139,32 @@ type ContextExpression struct {
 
 // Implements IExpression
 type VectorExpression struct {
-	Values   []IExpression
+	Values []IExpression
+	// Location of the entire vector including the brackets
 	Location errlog.LocationRange
 }
+
+// Returns the location of the source code from which the expression has been parsed.
+func ExpressionLocation(expr IExpression) errlog.LocationRange {
+	switch t := expr.(type) {
+	case *BinaryExpression:
+		return ExpressionLocation(t.Left).Join(ExpressionLocation(t.Right))
+	case *UnaryExpression:
+		return t.Op.Location.Join(ExpressionLocation(t.Operand))
+	case *IdentifierExpression:
+		return t.Identifier.Location
+	case *ConstantExpression:
+		return t.Value.Location
+	case *DimensionExpression:
+		return ExpressionLocation(t.Value).Join(t.Dimension.Location)
+	case *CallExpression:
+		return t.Location
+	case *DotExpression:
+		return ExpressionLocation(t.Context).Join(t.Identifier.Location)
+	case *ContextExpression:
+		return ExpressionLocation(t.Object)
+	case *VectorExpression:
+		return t.Location
+	}
+	return errlog.LocationRange{}
+}
@@ -56,6 +56,40 @@ func (p *Parser) parseFile(f *File) {
 					return
 				}
 				f.Statements = append(f.Statements, ground)
+			} else if t.StringValue == "rules" {
+				rules, err := p.parseRules(t)
+				if err != nil {
+					p.log.AddError(err)
+					return
+				}
+				f.Statements = append(f.Statements, rules)
+			} else if t.StringValue == "decoders" {
+				d, err := p.parseDecoders(t)
+				if err != nil {
+					p.log.AddError(err)
+					return
+				}
+				f.Statements = append(f.Statements, d)
+			} else if t.StringValue == "geometry" {
+				g, err := p.parseGeometry(t)
+				if err != nil {
+					p.log.AddError(err)
+					return
+				}
+				f.Statements = append(f.Statements, g)
+			} else if t.StringValue == "block" {
+				bl, err := p.parseBlock(t)
+				if err != nil {
+					p.log.AddError(err)
+					return
+				}
+				f.Statements = append(f.Statements, bl)
+			} else if t.StringValue == "system" {
+				sys, err := p.parseSystem(t)
+				if err != nil {
+					return
+				}
+				f.Statements = append(f.Statements, sys)
 			} else if t.StringValue == "switchboard" {
 				sb, err := p.parseSwitchboard(t)
 				if err != nil {
@@ -130,6 +164,58 @@ func (p *Parser) parseLayer(t *Token) (*Layer, *errlog.Error) {
 	return l, nil
 }
 
+func (p *Parser) parseGeometry(t *Token) (*Geometry, *errlog.Error) {
+	g := &Geometry{Location: t.Location}
+	var err *errlog.Error
+
+	// Parse name
+	g.Name, err = p.expect(TokenIdentifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.expect(TokenOpenBraces); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(TokenNewline); err != nil {
+		return nil, err
+	}
+
+	// Parse body
+	g.Expressions, err = p.parseBody()
+	if err != nil {
+		return nil, err
+	}
+
+	return g, nil
+}
+
+func (p *Parser) parseBlock(t *Token) (*Block, *errlog.Error) {
+	bl := &Block{Location: t.Location}
+	var err *errlog.Error
+
+	// Parse name
+	bl.Name, err = p.expect(TokenIdentifier)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := p.expect(TokenOpenBraces); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(TokenNewline); err != nil {
+		return nil, err
+	}
+
+	// Parse body
+	bl.Expressions, err = p.parseBody()
+	if err != nil {
+		return nil, err
+	}
+
+	return bl, nil
+}
+
 func (p *Parser) parseTracks(t *Token) (*Tracks, *errlog.Error) {
 	tracks := &Tracks{Location: t.Location}
 
@@ -137,7 +223,8 @@ func (p *Parser) parseTracks(t *Token) (*Tracks, *errlog.Error) {
 	if t, ok := p.optional(TokenIdentifier); ok {
 		tracks.Name = t
 		if _, ok := p.optional(TokenOpenParanthesis); ok {
-			var params []*Parameter
+			// Not nil, even if the parameter list is empty
+			params := []*Parameter{}
 			for {
 				if _, ok := p.optional(TokenCloseParanthesis); ok {
 					break
@@ -246,8 +333,8 @@ func (p *Parser) parseCallExpression() (IExpression, *errlog.Error) {
 	if _, ok := p.optional(TokenOpenParanthesis); ok {
 		var args []IExpression
 		for {
-			if _, ok := p.optional(TokenCloseParanthesis); ok {
-				break
+			if t, ok := p.optional(TokenCloseParanthesis); ok {
+				return &CallExpression{Func: expr, Arguments: args, Location: ExpressionLocation(expr).Join(t.Location)}, nil
 			}
 			if len(args) != 0 {
 				if _, err := p.expect(TokenComma); err != nil {
@@ -260,17 +347,25 @@ func (p *Parser) parseCallExpression() (IExpression, *errlog.Error) {
 			}
 			args = append(args, arg)
 		}
-		return &CallExpression{Func: expr, Arguments: args}, nil
 	}
 	return expr, nil
 }
 
 func (p *Parser) parseSimpleExpression() (IExpression, *errlog.Error) {
-	t, err := p.expectMulti(TokenIdentifier, TokenAt, TokenString, TokenInteger, TokenFloat, TokenOpenParanthesis, TokenOpenBracket)
+	t, err := p.expectMulti(TokenIdentifier, TokenAt, TokenString, TokenInteger, TokenFloat, TokenOpenParanthesis, TokenOpenBracket, TokenDash)
 	if err != nil {
 		return nil, err
 	}
 
+	// Negative numbers, e.g. `-5 mm`
+	if t.Kind == TokenDash {
+		expr, err := p.parseSimpleExpression()
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryExpression{Op: t, Operand: expr}, nil
+	}
+
 	// For strings and @, dimensions are not allowed
 	if t.Kind == TokenString {
 		return &ConstantExpression{Value: t}, nil
@@ -279,8 +374,8 @@ func (p *Parser) parseSimpleExpression() (IExpression, *errlog.Error) {
 	} else if t.Kind == TokenOpenBracket {
 		var values []IExpression
 		for {
-			if _, ok := p.optional(TokenCloseBracket); ok {
-				break
+			if t2, ok := p.optional(TokenCloseBracket); ok {
+				return &VectorExpression{Values: values, Location: t.Location.Join(t2.Location)}, nil
 			}
 			if len(values) != 0 {
 				if _, err := p.expect(TokenComma); err != nil {
@@ -293,7 +388,6 @@ func (p *Parser) parseSimpleExpression() (IExpression, *errlog.Error) {
 			}
 			values = append(values, value)
 		}
-		return &VectorExpression{Values: values, Location: t.Location}, nil
 	}
 
 	var expr IExpression
@@ -344,6 +438,59 @@ func (p *Parser) parseGround(t *Token) (*GroundPlate, *errlog.Error) {
 	return ground, nil
 }
 
+func (p *Parser) parseRules(t *Token) (*Rules, *errlog.Error) {
+	if _, err := p.expect(TokenOpenBraces); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(TokenNewline); err != nil {
+		return nil, err
+	}
+	rules := &Rules{Location: t.Location}
+
+	// Parse body
+	var err *errlog.Error
+	rules.Expressions, err = p.parseBody()
+	if err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
+
+func (p *Parser) parseDecoders(t *Token) (*Decoders, *errlog.Error) {
+	if _, err := p.expect(TokenOpenBraces); err != nil {
+		return nil, err
+	}
+	if _, err := p.expect(TokenNewline); err != nil {
+		return nil, err
+	}
+	d := &Decoders{Location: t.Location}
+
+	// Parse body
+	var err *errlog.Error
+	d.Expressions, err = p.parseBody()
+	if err != nil {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (p *Parser) parseSystem(t *Token) (*System, *errlog.Error) {
+	name, err := p.expectMulti(TokenString, TokenIdentifier)
+	if err != nil {
+		return nil, err
+	}
+	end, err := p.expectMulti(TokenNewline, TokenEOF)
+	if err != nil {
+		return nil, err
+	}
+	if end.Kind == TokenEOF {
+		p.savedToken = end
+	}
+	return &System{Name: name, Location: t.Location}, nil
+}
+
 func (p *Parser) parseSwitchboard(t *Token) (sb *Switchboard, err *errlog.Error) {
 	_, err = p.expect(TokenOpenBraces)
 	if err != nil {
@@ -126,6 +126,8 @@ type Token struct {
 	FloatValue   *big.Float
 	ErrorCode    errlog.ErrorCode
 	Location     errlog.LocationRange
+	// True for identifiers which have been quoted with backticks
+	Quoted bool
 }
 
 type tokenDefinition struct {
@@ -351,7 +353,7 @@ func (t *tokenizer) scan(file int, str string, i int) (token *Token, pos int) {
 			loc := encodeRange(file, str, j, j2)
 			return t.errorToken(errlog.ErrorIllegalString, loc), j2
 		}
-		token := &Token{Kind: TokenIdentifier, StringValue: string(value), Location: encodeRange(file, str, i, j+1)}
+		token := &Token{Kind: TokenIdentifier, StringValue: string(value), Location: encodeRange(file, str, i, j+1), Quoted: true}
 		return token, j + 1
 	}
 	if ch == '\'' {
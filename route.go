@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/weistn/ferrovia/analysis"
+	"github.com/weistn/ferrovia/model/routing"
+	"github.com/weistn/ferrovia/model/tracks"
+)
+
+func runRoute(args []string) int {
+	fs := flag.NewFlagSet("route", flag.ContinueOnError)
+	fromName := fs.String("from", "", "Name of the `mark` where the routes start")
+	toName := fs.String("to", "", "Name of the `mark` where the routes end. If missing, all other marks are routed")
+	all := fs.Bool("all", false, "List all routes and not only the shortest one")
+	max := fs.Int("max", 100, "The maximum `number` of routes listed by -all for each mark")
+	filename, ok := parseCommandLine(fs, args)
+	if !ok {
+		return 2
+	}
+	if *fromName == "" {
+		fmt.Fprint(os.Stderr, "Missing -from\n")
+		fs.Usage()
+		return 2
+	}
+
+	m, log, err := loadFile(filename, analysis.DefaultConfig())
+	if log == nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	if err != nil {
+		return 1
+	}
+
+	from := m.Tracks.GetMark(*fromName)
+	if from == nil {
+		fmt.Fprintf(os.Stderr, "Unknown mark %v\n", *fromName)
+		return 1
+	}
+	var targets []*tracks.TrackMark
+	if *toName != "" {
+		to := m.Tracks.GetMark(*toName)
+		if to == nil {
+			fmt.Fprintf(os.Stderr, "Unknown mark %v\n", *toName)
+			return 1
+		}
+		targets = append(targets, to)
+	} else {
+		for _, mark := range m.Tracks.Marks() {
+			if mark != from {
+				targets = append(targets, mark)
+			}
+		}
+	}
+
+	// The exit code signals whether all marks can be reached
+	code := 0
+	for _, to := range targets {
+		// Enumerating all routes takes exponential time, hence it is avoided unless requested
+		var routes []*routing.Route
+		complete := true
+		if *all {
+			routes, complete = routing.Routes(from, to, *max)
+		} else if r := routing.Shortest(from, to); r != nil {
+			routes = append(routes, r)
+		}
+		if len(routes) == 0 {
+			fmt.Printf("%v -> %v: unreachable\n", from.Name(), to.Name())
+			code = 1
+			continue
+		}
+		for _, r := range routes {
+			fmt.Printf("%v -> %v: %.1f mm\n", from.Name(), to.Name(), r.Length)
+			for _, s := range r.Settings {
+				fmt.Printf("\t%v at %v:%v option %v\n", s.Turnout.Geometry.Name, s.Turnout.SourceLocation.Line(), s.Turnout.SourceLocation.Position(), s.Option)
+			}
+		}
+		if !complete {
+			fmt.Printf("%v -> %v: stopped after %v routes, the shortest route may be missing\n", from.Name(), to.Name(), *max)
+		}
+	}
+	return code
+}
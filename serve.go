@@ -0,0 +1,227 @@
+package main
+
+import (
+	"embed"
+	"flag"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/weistn/ferrovia/analysis"
+	"github.com/weistn/ferrovia/control"
+	"github.com/weistn/ferrovia/control/station"
+	"github.com/weistn/ferrovia/model"
+	"github.com/weistn/ferrovia/model/tracks"
+	"github.com/weistn/ferrovia/view/switchboard"
+	"github.com/weistn/goui"
+)
+
+type WindowAPI struct {
+}
+
+/*
+var demodata string = `{
+"tracks": [
+	{"c": 5, "r": 5, "kind": 20},
+	{"c": 6, "r": 5, "kind": 20},
+	{"c": 7, "r": 5, "kind": 20}
+],
+"columns": 20,
+"rows": 20
+}`
+*/
+
+//go:embed view/*.html view/*.css view/*.js view/fonts view/switchboard/*.js view/switchboard/*.css view/tracks2d/*.js view/tracks2d/*.css
+var uiFS embed.FS
+
+var window *goui.Window
+
+// The layout shown in the window. The UI operates its turnouts, signals and blocks.
+var shown *control.Layout
+
+// Guards shown, which is replaced when the file changes.
+var shownLock sync.Mutex
+
+// Drives the shown layout.
+var commandStation station.CommandStation
+
+// Asks the goroutine which loads the file to send the changed state of the shown layout to the window.
+// Files are loaded and sent by this goroutine only, since loading and rendering tag the tracks.
+var redraw = make(chan struct{}, 1)
+
+// Loads the file and shows it in the window. The layout of the file replaces the shown layout.
+func showFile(filename string) error {
+	m, log, err := loadFile(filename, analysis.DefaultConfig())
+	if err != nil {
+		return err
+	}
+	log.Print()
+	m.Name = "Demo"
+
+	l := control.NewLayout(m)
+	if prev := shownLayout(); prev != nil {
+		err = l.TakeOver(prev)
+	} else {
+		err = l.Drive(commandStation)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+	}
+	l.Listen(func(change control.Change, state *control.State) {
+		// The layout is locked, hence the state is sent by another goroutine
+		select {
+		case redraw <- struct{}{}:
+		default:
+		}
+	})
+	// The layout is not shared yet, hence the switchboard can read its turnouts
+	if err := window.SendEvent("layout", switchboard.Render(m.Switchboards)); err != nil {
+		fmt.Fprint(os.Stderr, err.Error())
+		return err
+	}
+	if err := sendState(m, l.State()); err != nil {
+		return err
+	}
+	shownLock.Lock()
+	shown = l
+	shownLock.Unlock()
+	return nil
+}
+
+// Returns the shown layout or nil.
+func shownLayout() *control.Layout {
+	shownLock.Lock()
+	defer shownLock.Unlock()
+	return shown
+}
+
+// Sends the track plan with the routes selected by the turnouts, the active route and occupied blocks and the state of the layout to the window.
+// The selected options are taken from the state, since the turnouts of a shown layout can change meanwhile.
+func sendState(m *model.Model, state *control.State) error {
+	canvas := renderCanvas(m)
+	var turnouts []*tracks.Track
+	var options []int
+	for _, l := range m.Tracks.Layers {
+		for _, t := range l.Tracks {
+			if t.Geometry.IsTurnout() {
+				turnouts = append(turnouts, t)
+				options = append(options, state.Options[t])
+			}
+		}
+	}
+	canvas.HighlightOptions("active", turnouts, options)
+	if state.Route != nil {
+		canvas.Highlight("route", state.Route.Tracks)
+	}
+	for _, b := range state.Blocks {
+		if b.Occupied {
+			canvas.Highlight("occupied", m.Block(b.Name).Tracks)
+		}
+	}
+	events := []struct {
+		name string
+		data interface{}
+	}{{"canvas", canvas}, {"state", state}}
+	for _, e := range events {
+		if err := window.SendEvent(e.name, e.data); err != nil {
+			fmt.Fprint(os.Stderr, err.Error())
+			return err
+		}
+	}
+	return nil
+}
+
+// Opens the command station given by the -station flag.
+func openStation(name string) (station.CommandStation, error) {
+	if name == "sim" {
+		sim := station.NewSimulator()
+		sim.Log = os.Stdout
+		return sim, nil
+	}
+	if strings.HasPrefix(name, "z21:") {
+		return station.DialZ21(strings.TrimPrefix(name, "z21:"))
+	}
+	return nil, fmt.Errorf("unknown command station %v", name)
+}
+
+func runServe(args []string) int {
+	flags := flag.NewFlagSet("serve", flag.ContinueOnError)
+	stationName := flags.String("station", "sim", "The command station which drives the layout, either sim to print the commands or z21:`host`")
+	filename, ok := parseCommandLine(flags, args)
+	if !ok {
+		return 2
+	}
+	cs, err := openStation(*stationName)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		return 1
+	}
+	defer cs.Close()
+	commandStation = cs
+
+	//
+	// Open UI in browser
+	//
+
+	remote := &WindowAPI{}
+
+	window = goui.NewWindow("/", remote, nil)
+	subfs, err := fs.Sub(uiFS, "view")
+	if err != nil {
+		panic("Embedding failed")
+	}
+	window.Handle("/api/", http.StripPrefix("/api", control.NewAPI(shownLayout)))
+	window.Handle("/", http.FileServer(http.FS(subfs)))
+	err = window.Start()
+	if err != nil {
+		fmt.Fprint(os.Stderr, err.Error())
+		return 1
+	}
+
+	//
+	// Watch file
+	//
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		fmt.Fprint(os.Stderr, "Could not watch file", err)
+		return 1
+	}
+	defer watcher.Close()
+	err = watcher.Add(filename)
+	if err != nil {
+		fmt.Fprint(os.Stderr, "Could not watch file "+filename, err)
+	}
+
+	go func() {
+		showFile(filename)
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				// fmt.Fprintf(os.Stderr, "%s %s\n", event.Name, event.Op)
+				if event.Op == fsnotify.Create || event.Op == fsnotify.Write {
+					showFile(filename)
+				}
+			case <-redraw:
+				if l := shownLayout(); l != nil {
+					sendState(l.Model(), l.State())
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				fmt.Fprintf(os.Stderr, "error: %v\n", err)
+			}
+		}
+
+	}()
+
+	window.Wait()
+	return 0
+}
@@ -0,0 +1,141 @@
+package bom
+
+import (
+	"encoding/csv"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/weistn/ferrovia/model"
+	"github.com/weistn/ferrovia/model/tracks"
+)
+
+// BillOfMaterials lists how many pieces of each track type a layout requires.
+type BillOfMaterials struct {
+	Name string `json:"name"`
+	// All pieces of the layout
+	Total []*Item `json:"total"`
+	// Pieces per layer
+	Layers []*Group `json:"layers"`
+	// Pieces per named tracks block
+	Blocks []*Group `json:"blocks"`
+}
+
+type Group struct {
+	Name  string  `json:"name"`
+	Items []*Item `json:"items"`
+}
+
+// An Item counts the pieces of one track geometry.
+// Left and right curves of the same kind share one geometry and hence one Item.
+type Item struct {
+	// Name of the track geometry, e.g. G1 or WL15
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+	// True for pieces which are not available from the vendor,
+	// but must be cut from flexible track.
+	Custom bool `json:"custom,omitempty"`
+	// Radius in mm of custom curves.
+	Radius float64 `json:"radius,omitempty"`
+	// Angle in degree of custom curves.
+	Angle float64 `json:"angle,omitempty"`
+	// Length in mm of the main route through all pieces.
+	Length float64 `json:"length"`
+}
+
+func Render(m *model.Model) *BillOfMaterials {
+	bom := &BillOfMaterials{Name: m.Name}
+	total := make(map[string]*Item)
+	layers := make(map[string]map[string]*Item)
+	blocks := make(map[string]map[string]*Item)
+	for _, l := range m.Tracks.Layers {
+		for _, t := range l.Tracks {
+			count(total, t)
+			if _, ok := layers[l.Name]; !ok {
+				layers[l.Name] = make(map[string]*Item)
+			}
+			count(layers[l.Name], t)
+			if t.Group != "" {
+				if _, ok := blocks[t.Group]; !ok {
+					blocks[t.Group] = make(map[string]*Item)
+				}
+				count(blocks[t.Group], t)
+			}
+		}
+	}
+	bom.Total = sortItems(total)
+	bom.Layers = sortGroups(layers)
+	bom.Blocks = sortGroups(blocks)
+	return bom
+}
+
+func count(items map[string]*Item, t *tracks.Track) {
+	item, ok := items[t.Geometry.Name]
+	if !ok {
+		item = &Item{Name: t.Geometry.Name, Custom: t.Geometry.Custom}
+		if t.Geometry.Custom && len(t.Geometry.Paths) == 1 {
+			if arc, ok := t.Geometry.Paths[0].(*tracks.TrackGeometryArc); ok {
+				item.Radius = arc.Radius
+				item.Angle = arc.TrackAngle
+			}
+		}
+		items[t.Geometry.Name] = item
+	}
+	item.Count++
+	item.Length += t.Geometry.Length()
+}
+
+func sortItems(items map[string]*Item) []*Item {
+	var result []*Item
+	for _, item := range items {
+		result = append(result, item)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+func sortGroups(groups map[string]map[string]*Item) []*Group {
+	var result []*Group
+	for name, items := range groups {
+		result = append(result, &Group{Name: name, Items: sortItems(items)})
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
+
+// WriteCSV writes one line per item. The first two columns denote the scope, i.e.
+// `total`, `layer` or `block`, and the name of the layer or block.
+func (bom *BillOfMaterials) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"scope", "group", "piece", "count", "custom", "radius", "angle", "length"})
+	writeItems(cw, "total", "", bom.Total)
+	for _, g := range bom.Layers {
+		writeItems(cw, "layer", g.Name, g.Items)
+	}
+	for _, g := range bom.Blocks {
+		writeItems(cw, "block", g.Name, g.Items)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func writeItems(cw *csv.Writer, scope string, group string, items []*Item) {
+	for _, item := range items {
+		radius := ""
+		angle := ""
+		if item.Custom && item.Radius != 0 {
+			radius = formatFloat(item.Radius)
+			angle = formatFloat(item.Angle)
+		}
+		cw.Write([]string{scope, group, item.Name, strconv.Itoa(item.Count), strconv.FormatBool(item.Custom), radius, angle, formatFloat(item.Length)})
+	}
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(math.Round(f*10)/10, 'f', -1, 64)
+}
@@ -0,0 +1,171 @@
+package bom
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/weistn/ferrovia/errlog"
+	"github.com/weistn/ferrovia/interpreter"
+	"github.com/weistn/ferrovia/model/tracks"
+	"github.com/weistn/ferrovia/parser"
+)
+
+const bomData = `layer hidden {
+	color("#888888")
+}
+
+tracks {
+	@(0 mm, 0 mm, 0 mm, 90 deg)
+	G1 R5 L5 G1
+}
+
+tracks station {
+	@(0 mm, 1000 mm, 0 mm, 90 deg)
+	G1 WL15
+}
+
+tracks {
+	layer("hidden")
+	@(0 mm, 2000 mm, 0 mm, 90 deg)
+	L5
+}
+`
+
+func TestRender(t *testing.T) {
+	tracks.InitRoco()
+	e := errlog.NewErrorLog()
+	fileId := e.AddFile(errlog.NewSourceFile("data"))
+	file := parser.NewParser(e).Parse(fileId, bomData)
+	m := interpreter.NewInterpreter(e).ProcessStatics(file)
+	if e.HasErrors() {
+		e.Print()
+		t.Fatal("Unexpected errors")
+	}
+	m.Name = "test"
+	bom := Render(m)
+
+	counts := func(items []*Item) map[string]int {
+		result := make(map[string]int)
+		for _, item := range items {
+			result[item.Name] = item.Count
+		}
+		return result
+	}
+	groups := func(groups []*Group) map[string]map[string]int {
+		result := make(map[string]map[string]int)
+		for _, g := range groups {
+			result[g.Name] = counts(g.Items)
+		}
+		return result
+	}
+	layers := groups(bom.Layers)
+	blocks := groups(bom.Blocks)
+	for _, c := range []struct {
+		scope  string
+		counts map[string]int
+		piece  string
+		count  int
+	}{
+		// Left curves are counted as right curves
+		{"total", counts(bom.Total), "G1", 3},
+		{"total", counts(bom.Total), "R5", 3},
+		{"total", counts(bom.Total), "L5", 0},
+		{"total", counts(bom.Total), "WL15", 1},
+		{"layer hidden", layers["hidden"], "R5", 1},
+		{"layer hidden", layers["hidden"], "G1", 0},
+		{"layer default", layers[""], "R5", 2},
+		{"block station", blocks["station"], "G1", 1},
+		{"block station", blocks["station"], "WL15", 1},
+		{"block station", blocks["station"], "R5", 0},
+	} {
+		if c.counts[c.piece] != c.count {
+			t.Fatalf("%v: %v %v pieces instead of %v", c.scope, c.counts[c.piece], c.piece, c.count)
+		}
+	}
+	if len(bom.Blocks) != 1 {
+		t.Fatalf("Unexpected blocks %v", bom.Blocks)
+	}
+
+	var b bytes.Buffer
+	if err := bom.WriteCSV(&b); err != nil {
+		t.Fatal(err)
+	}
+	csv := b.String()
+	for _, line := range []string{"scope,group,piece,count,custom,radius,angle,length\n", "total,,R5,3,false,,,", "layer,hidden,R5,1,false,,,", "block,station,WL15,1,false,,,"} {
+		if !strings.Contains(csv, line) {
+			t.Fatalf("Missing %q in CSV:\n%v", line, csv)
+		}
+	}
+
+	b.Reset()
+	if err := json.NewEncoder(&b).Encode(bom); err != nil {
+		t.Fatal(err)
+	}
+	var decoded BillOfMaterials
+	if err := json.Unmarshal(b.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Name != "test" || len(decoded.Total) != len(bom.Total) || decoded.Blocks[0].Name != "station" || decoded.Total[0].Length != bom.Total[0].Length {
+		t.Fatalf("Unexpected JSON %v", b.String())
+	}
+}
+
+const customData = `geometry R700 {
+	curve(700 mm, 15 deg)
+	reversed("L700")
+}
+
+tracks {
+	@(0 mm, 0 mm, 0 mm, 90 deg)
+	RC5 LC5 R700 G1
+}
+`
+
+// Pieces which must be cut from flexible track are listed with their radius and angle
+func TestCustomPieces(t *testing.T) {
+	tracks.InitRoco()
+	e := errlog.NewErrorLog()
+	fileId := e.AddFile(errlog.NewSourceFile("data"))
+	file := parser.NewParser(e).Parse(fileId, customData)
+	m := interpreter.NewInterpreter(e).ProcessStatics(file)
+	if e.HasErrors() {
+		e.Print()
+		t.Fatal("Unexpected errors")
+	}
+	bom := Render(m)
+
+	var b bytes.Buffer
+	if err := json.NewEncoder(&b).Encode(bom); err != nil {
+		t.Fatal(err)
+	}
+	var decoded BillOfMaterials
+	if err := json.Unmarshal(b.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	items := make(map[string]*Item)
+	for _, item := range decoded.Total {
+		items[item.Name] = item
+	}
+	if c5 := items["C5"]; c5 == nil || c5.Count != 2 || !c5.Custom || c5.Radius != 542.8 || c5.Angle != 5 {
+		t.Fatalf("Wrong custom curve %+v", c5)
+	}
+	if r700 := items["R700"]; r700 == nil || r700.Count != 1 || !r700.Custom || r700.Radius != 700 || r700.Angle != 15 {
+		t.Fatalf("Wrong geometry %+v", r700)
+	}
+	if g1 := items["G1"]; g1 == nil || g1.Custom || g1.Radius != 0 || g1.Angle != 0 {
+		t.Fatalf("Wrong vendor piece %+v", g1)
+	}
+
+	b.Reset()
+	if err := bom.WriteCSV(&b); err != nil {
+		t.Fatal(err)
+	}
+	csv := b.String()
+	for _, line := range []string{"total,,C5,2,true,542.8,5,", "total,,R700,1,true,700,15,", "total,,G1,1,false,,,"} {
+		if !strings.Contains(csv, line) {
+			t.Fatalf("Missing %q in CSV:\n%v", line, csv)
+		}
+	}
+}
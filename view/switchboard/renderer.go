@@ -15,6 +15,12 @@ type Track struct {
 	Y    int              `json:"r"`
 	Text string           `json:"t,omitempty"`
 	Kind sb.ASCIICellType `json:"kind"`
+	// The label of a switch which operates a turnout.
+	Turnout string `json:"turnout,omitempty"`
+	// True if the turnout operated by the switch is set to a curved route.
+	Diverging bool `json:"diverging,omitempty"`
+	// The name of the block shown by a block.
+	Block string `json:"block,omitempty"`
 }
 
 func Render(layouts []*sb.ASCIISwitchboard) *TrackDiagram {
@@ -50,6 +56,10 @@ func Render(layouts []*sb.ASCIISwitchboard) *TrackDiagram {
 				} else if c.Type == sb.TrackDoubleBackslash {
 					d.Tracks = append(d.Tracks, &Track{X: x, Y: y, Kind: sb.TrackDiagonalBackLower})
 					d.Tracks = append(d.Tracks, &Track{X: x, Y: y, Kind: sb.TrackDiagonalBackUpper})
+				} else if c.Turnout != nil {
+					d.Tracks = append(d.Tracks, &Track{X: x, Y: y, Kind: c.Type, Turnout: c.Text, Diverging: c.Turnout.IsDiverging()})
+				} else if c.IsBlock() {
+					d.Tracks = append(d.Tracks, &Track{X: x, Y: y, Kind: c.Type, Block: c.Text})
 				} else if c.Type != sb.UnprocessedCell && c.Type < 100 {
 					d.Tracks = append(d.Tracks, &Track{X: x, Y: y, Kind: c.Type})
 				}
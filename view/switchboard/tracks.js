@@ -70,13 +70,16 @@ class TrackDiagram {
             var t;
             var cell = dgrm.grid[obj.c][obj.r];
             if (obj.kind <= switchHorizontalDiagonalLower) {
-                t = new TrackSwitch(cell, obj.kind);
+                t = new TrackSwitch(cell, obj.kind, obj.turnout, obj.diverging);
+                if (obj.turnout) {
+                    cell.svgBG.addEventListener("click", () => {switchTurnout(obj.turnout);});
+                }
             } else if (obj.kind <= switchHorizontalCrossDiagonalBack) {
                 t = new TrackSwitchCross(cell, obj.kind);
             } else if (obj.kind <= trackVerticalStopBottom) {
                 t = new TrackSimple(cell, obj.kind);
             } else if (obj.kind <= trackVerticalBlock) {
-                t = new TrackBlock(cell, obj.kind);
+                t = new TrackBlock(cell, obj.kind, obj.block);
             } else if (obj.kind == trackHorizontalLabel || obj.kind == trackVerticalLabel) {
                 t = new TrackLabel(cell, obj.kind, obj.t);
             } else {
@@ -85,6 +88,43 @@ class TrackDiagram {
             // Call addTrack here, because multiple tracks can occupy a cell.
             cell.addTrack(t);
         }
+        TrackDiagram.current = dgrm;
+        if (TrackDiagram.state) {
+            dgrm.applyState(TrackDiagram.state);
+        }
+    }
+
+    // Shows the state of the layout as sent by the server, i.e. the routes selected by the turnouts and the trains occupying the blocks.
+    // The state is applied again when the diagram is replaced.
+    static setState(state) {
+        TrackDiagram.state = state;
+        if (TrackDiagram.current) {
+            TrackDiagram.current.applyState(state);
+        }
+    }
+
+    applyState(state) {
+        var blocks = {};
+        for (var b of state.blocks) {
+            blocks[b.name] = b;
+        }
+        var turnouts = {};
+        for (var s of state.turnouts) {
+            turnouts[s.name] = s;
+        }
+        for (var col of this.grid) {
+            for (var cell of col) {
+                var tracks = Array.isArray(cell.track) ? cell.track : [cell.track];
+                for (var t of tracks) {
+                    if (t instanceof TrackBlock && t.block && blocks[t.block]) {
+                        var b = blocks[t.block];
+                        t.setTrain(b.occupied ? new Train(b.train || "?") : null);
+                    } else if (t instanceof TrackSwitch && t.turnout && turnouts[t.turnout]) {
+                        t.setDiverging(turnouts[t.turnout].diverging);
+                    }
+                }
+            }
+        }
     }
 }
 
@@ -391,8 +431,12 @@ class TrackSimple extends TrackElement {
  
 // A track with a single switch
 class TrackSwitch extends TrackElement {
-     constructor(cell, kind) {
+     // @param turnout is the label of the switch if it operates a turnout.
+     // @param diverging is true if the turnout is set to its curved route.
+     constructor(cell, kind, turnout, diverging) {
          super(cell, kind);
+         this.turnout = turnout;
+         this.diverging = diverging;
          if (kind == switchHorizontalCrossDiagonal || kind == switchHorizontalCrossDiagonalBack || kind == switchVerticalCrossDiagonal || kind == switchVerticalCrossDiagonalBack) {
              throw "Ooops";
          }
@@ -458,10 +502,32 @@ class TrackSwitch extends TrackElement {
         } else {
             throw "Oooops";
         }
+
+        // Switches which operate a turnout show the route which is not selected as inactive
+        this.svgRect = rect;
+        this.svgPath = path;
+        if (this.turnout) {
+            (this.diverging ? rect : path).classList.add("track-inactive");
+        }
+    }
+
+    // Shows whether the turnout operated by the switch is set to its curved route.
+    setDiverging(diverging) {
+        this.diverging = diverging;
+        if (this.svgRect) {
+            this.svgRect.classList.toggle("track-inactive", diverging);
+            this.svgPath.classList.toggle("track-inactive", !diverging);
+        }
     }
 
 }
 
+// Asks the server to switch the turnout operated by the switch of the given label.
+// The server answers by sending the updated layout and state.
+function switchTurnout(turnout) {
+    fetch("/api/turnout", {method: "POST", headers: {"Content-Type": "application/json"}, body: JSON.stringify({name: turnout})});
+}
+
 // A crossing track that can optionally switch.
 class TrackSwitchCross extends TrackElement {
     constructor(cell, kind) {
@@ -535,8 +601,10 @@ class TrackSwitchCross extends TrackElement {
  
 // A horizontal or vertical track that can display a train number.
 class TrackBlock extends TrackElement {
-    constructor(cell,kind) {
+    constructor(cell,kind, block) {
         super(cell, kind);
+        // The name of the block in the layout or undefined.
+        this.block = block;
     }
 
     createSVG(svg) {
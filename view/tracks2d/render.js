@@ -122,6 +122,32 @@ function renderCanvas(c, svgView, svgMeasure, svgGround, svgTracks) {
             }
         }
     }
+
+    // Highlighted tracks, e.g. tracks which cannot be reached
+    if (!c.highlights) {
+        c.highlights = [];
+    }
+    for (highlight of c.highlights) {
+        for (track of highlight.tracks) {
+            var d = "";
+            if (track.l) {
+                for (var line of track.l) {
+                    var dx = Math.sin(line.a * Math.PI/180) * line.l;
+                    var dy = -Math.cos(line.a * Math.PI/180) * line.l;
+                    d += [" M", line.x, line.y, "L", line.x + dx, line.y + dy].join(" ");
+                }
+            }
+            if (track.a) {
+                for (var arc of track.a) {
+                    d += " " + describeArc(arc.cx, arc.cy, arc.r, arc.sa, arc.sa + arc.ta);
+                }
+            }
+            var svgLine = document.createElementNS(svgNS, "path");
+            svgLine.classList.add("track-highlight", "track-highlight-" + highlight.kind);
+            svgLine.setAttribute("d", d);
+            svgTracks.appendChild(svgLine);
+        }
+    }
 }
 
 function polarToCartesian(centerX, centerY, radius, angleInDegrees) {
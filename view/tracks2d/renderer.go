@@ -2,6 +2,7 @@ package tracks2d
 
 import (
 	"math"
+	"sort"
 
 	"github.com/weistn/ferrovia/model"
 	"github.com/weistn/ferrovia/model/tracks"
@@ -14,10 +15,20 @@ type Canvas struct {
 	Width  float64              `json:"width"`
 	Height float64              `json:"height"`
 	Ground []*model.GroundPlate `json:"ground"`
+	// Tracks which are drawn emphasized on top of the layers.
+	Highlights []*Highlight `json:"highlights,omitempty"`
+}
+
+// A Highlight emphasizes some tracks, e.g. the tracks which the analysis found to be unreachable.
+type Highlight struct {
+	// Kind of the highlight. It determines the color.
+	Kind   string   `json:"kind"`
+	Tracks []*Track `json:"tracks"`
 }
 
 type Layer struct {
 	Name   string   `json:"name"`
+	Color  string   `json:"color,omitempty"`
 	Tracks []*Track `json:"tracks"`
 }
 
@@ -54,8 +65,15 @@ func Render(m *model.Model) *Canvas {
 	tracks.NewEpoch()
 	c := &Canvas{}
 	c.Name = m.Name
-	for _, l := range ts.Layers {
-		cl := &Layer{Name: l.Name}
+	// Iterate over the layers in a stable order
+	var names []string
+	for name := range ts.Layers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		l := ts.Layers[name]
+		cl := &Layer{Name: l.Name, Color: l.Color}
 		c.Layers = append(c.Layers, cl)
 		for _, track := range l.Tracks {
 			renderTrack(track, cl, c)
@@ -76,39 +94,43 @@ func Render(m *model.Model) *Canvas {
 	return c
 }
 
-func renderTrack(track *tracks.Track, cl *Layer, c *Canvas) {
-	t := &Track{}
-	for _, path := range track.Geometry.Paths {
-		switch p := path.(type) {
-		case *tracks.TrackGeometryLine:
-			from := track.Location.Center.Add2(p.Anchor.Position.Rotate(track.Location.Rotation))
-			startAngle := track.Location.Rotation + p.Anchor.Angle
-			length := p.Size
-			t.Lines = append(t.Lines, &Line{X: from[0], Y: from[1], Angle: normalizeAngle(startAngle), Length: length})
-
-			to := from.Add2(tracks.Vec2{0, -length}.Rotate(startAngle))
-			c.Width = math.Max(math.Max(from[0]+100, c.Width), to[0]+100)
-			c.Height = math.Max(math.Max(from[1]+100, c.Height), to[1]+100)
-		case *tracks.TrackGeometryArc:
-			from := track.Location.Center.Add2(p.Anchor.Position.Rotate(track.Location.Rotation))
-			angle := track.Location.Rotation + p.Anchor.Angle
-			trackAngle := p.TrackAngle
-			radius := p.Radius
-			sin := math.Sin(angle * math.Pi / 180)
-			cos := math.Cos(angle * math.Pi / 180)
-			x := cos
-			y := sin
-			centerX := from[0] + x*radius
-			centerY := from[1] + y*radius
-			t.Arcs = append(t.Arcs, &Arc{CenterX: centerX, CenterY: centerY, Radius: radius, StartAngle: normalizeAngle(angle - 90), TrackAngle: trackAngle})
-
-			maxSize := 2 * math.Pi * radius * trackAngle / 360
-			c.Width = math.Max(math.Max(from[0], c.Width), from[0]+maxSize)
-			c.Height = math.Max(math.Max(from[1], c.Height), from[1]+maxSize)
-		default:
-			panic("Not implemented")
+// Adds a highlight of the given kind for the tracks. Tracks without a location are ignored.
+func (c *Canvas) Highlight(kind string, ts []*tracks.Track) {
+	h := &Highlight{Kind: kind}
+	for _, track := range ts {
+		if track.Location != nil {
+			h.Tracks = append(h.Tracks, renderPaths(track, c))
+		}
+	}
+	if len(h.Tracks) != 0 {
+		c.Highlights = append(c.Highlights, h)
+	}
+}
+
+// Adds a highlight of the given kind for options of the turnouts, e.g. to show the selected routes.
+// options[i] is the option of turnouts[i]. Turnouts without a path for their option are highlighted as a whole.
+func (c *Canvas) HighlightOptions(kind string, turnouts []*tracks.Track, options []int) {
+	h := &Highlight{Kind: kind}
+	for i, track := range turnouts {
+		if track.Location == nil {
+			continue
+		}
+		path := track.Geometry.OptionPath(options[i])
+		if path == nil {
+			h.Tracks = append(h.Tracks, renderPaths(track, c))
+			continue
 		}
+		t := &Track{}
+		renderPath(track, path, t, c)
+		h.Tracks = append(h.Tracks, t)
 	}
+	if len(h.Tracks) != 0 {
+		c.Highlights = append(c.Highlights, h)
+	}
+}
+
+func renderTrack(track *tracks.Track, cl *Layer, c *Canvas) {
+	t := renderPaths(track, c)
 	track.Tag()
 	for i := 0; i < track.ConnectionCount(); i++ {
 		c := track.Connection(i)
@@ -122,6 +144,48 @@ func renderTrack(track *tracks.Track, cl *Layer, c *Canvas) {
 	cl.Tracks = append(cl.Tracks, t)
 }
 
+// Renders the lines and arcs of a track and enlarges the canvas such that they fit.
+func renderPaths(track *tracks.Track, c *Canvas) *Track {
+	t := &Track{}
+	for _, path := range track.Geometry.Paths {
+		renderPath(track, path, t, c)
+	}
+	return t
+}
+
+// Renders one path of a track into t and enlarges the canvas such that it fits.
+func renderPath(track *tracks.Track, path tracks.ITrackGeometryPath, t *Track, c *Canvas) {
+	switch p := path.(type) {
+	case *tracks.TrackGeometryLine:
+		from := track.Location.Center.Add2(p.Anchor.Position.Rotate(track.Location.Rotation))
+		startAngle := track.Location.Rotation + p.Anchor.Angle
+		length := p.Size
+		t.Lines = append(t.Lines, &Line{X: from[0], Y: from[1], Angle: normalizeAngle(startAngle), Length: length})
+
+		to := from.Add2(tracks.Vec2{0, -length}.Rotate(startAngle))
+		c.Width = math.Max(math.Max(from[0]+100, c.Width), to[0]+100)
+		c.Height = math.Max(math.Max(from[1]+100, c.Height), to[1]+100)
+	case *tracks.TrackGeometryArc:
+		from := track.Location.Center.Add2(p.Anchor.Position.Rotate(track.Location.Rotation))
+		angle := track.Location.Rotation + p.Anchor.Angle
+		trackAngle := p.TrackAngle
+		radius := p.Radius
+		sin := math.Sin(angle * math.Pi / 180)
+		cos := math.Cos(angle * math.Pi / 180)
+		x := cos
+		y := sin
+		centerX := from[0] + x*radius
+		centerY := from[1] + y*radius
+		t.Arcs = append(t.Arcs, &Arc{CenterX: centerX, CenterY: centerY, Radius: radius, StartAngle: normalizeAngle(angle - 90), TrackAngle: trackAngle})
+
+		maxSize := 2 * math.Pi * radius * trackAngle / 360
+		c.Width = math.Max(math.Max(from[0], c.Width), from[0]+maxSize)
+		c.Height = math.Max(math.Max(from[1], c.Height), from[1]+maxSize)
+	default:
+		panic("Not implemented")
+	}
+}
+
 func renderTrackDelimiter(pos tracks.Vec3, angle float64, size float64) *Delimiter {
 	sin := math.Sin(angle * math.Pi / 180)
 	cos := math.Cos(angle * math.Pi / 180)
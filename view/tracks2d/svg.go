@@ -0,0 +1,217 @@
+package tracks2d
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/weistn/ferrovia/model"
+)
+
+// Options for WriteSVG.
+type SVGOptions struct {
+	// Size of 1 mm of the layout in mm on paper.
+	// A value of 0.1 prints the layout in a scale of 1:10.
+	// A value of 0 is treated as 1.
+	Scale float64
+	// If true, dimension lines are drawn along the edges of all ground plates.
+	Dimensions bool
+}
+
+// Gauge of the tracks in mm. Used to draw the rails.
+const svgGauge = 16.4
+
+// Color used for the track bars if a layer has no color of its own.
+const svgDefaultTrackColor = "#446688"
+
+// Colors of the highlights by kind.
+var svgHighlightColors = map[string]string{"unreachable": "#dd2222", "reversing": "#9933cc"}
+
+const svgStyle = `
+.track-bars { fill: none; stroke-width: 24; stroke-dasharray: 3,3; stroke-dashoffset: 3; }
+.track-iron { fill: none; stroke: black; stroke-width: 1; }
+.track-delimiter { fill: none; stroke: orange; stroke-width: 2; }
+.highlight { fill: none; stroke-width: 40; stroke-opacity: 0.4; }
+.ground { fill: green; fill-opacity: 0.1; stroke: green; stroke-width: 1; }
+.dimension { fill: none; stroke: black; stroke-width: 1; }
+.dimension-text { font-family: Roboto, Arial, sans-serif; font-size: 24px; }
+`
+
+// WriteSVG writes a self-contained SVG image of the canvas to w.
+// All coordinates in the SVG are in mm.
+func WriteSVG(w io.Writer, c *Canvas, opts *SVGOptions) error {
+	scale := 1.0
+	if opts != nil && opts.Scale > 0 {
+		scale = opts.Scale
+	}
+	dimensions := opts != nil && opts.Dimensions
+	margin := 10.0
+	if dimensions {
+		// Room for the dimension lines and their labels
+		margin = 150
+	}
+	width := c.Width + 2*margin
+	height := c.Height + 2*margin
+
+	var b strings.Builder
+	b.WriteString("<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
+	fmt.Fprintf(&b, "<svg xmlns=\"http://www.w3.org/2000/svg\" width=\"%vmm\" height=\"%vmm\" viewBox=\"0 0 %v %v\">\n", svgNum(width*scale), svgNum(height*scale), svgNum(width), svgNum(height))
+	if c.Name != "" {
+		fmt.Fprintf(&b, "<title>%v</title>\n", html.EscapeString(c.Name))
+	}
+	fmt.Fprintf(&b, "<style>%v</style>\n", svgStyle)
+	fmt.Fprintf(&b, "<g transform=\"translate(%v %v)\">\n", svgNum(margin), svgNum(margin))
+
+	// Ground plates
+	b.WriteString("<g id=\"ground\">\n")
+	for _, ground := range c.Ground {
+		if len(ground.Polygon) != 0 {
+			var points []string
+			for _, p := range ground.Polygon {
+				points = append(points, svgNum(ground.Left+p.X)+","+svgNum(ground.Top+p.Y))
+			}
+			fmt.Fprintf(&b, "<polygon class=\"ground\" points=\"%v\"/>\n", strings.Join(points, " "))
+		} else {
+			fmt.Fprintf(&b, "<rect class=\"ground\" x=\"%v\" y=\"%v\" width=\"%v\" height=\"%v\"/>\n", svgNum(ground.Left), svgNum(ground.Top), svgNum(ground.Width), svgNum(ground.Height))
+		}
+	}
+	b.WriteString("</g>\n")
+
+	// Tracks
+	for _, l := range c.Layers {
+		color := l.Color
+		if color == "" {
+			color = svgDefaultTrackColor
+		}
+		fmt.Fprintf(&b, "<g id=\"layer-%v\">\n", html.EscapeString(l.Name))
+		for _, t := range l.Tracks {
+			writeSVGTrack(&b, t, color)
+		}
+		b.WriteString("</g>\n")
+	}
+
+	// Highlights
+	for _, h := range c.Highlights {
+		color, ok := svgHighlightColors[h.Kind]
+		if !ok {
+			color = "orange"
+		}
+		fmt.Fprintf(&b, "<g id=\"highlight-%v\">\n", html.EscapeString(h.Kind))
+		for _, t := range h.Tracks {
+			writeSVGHighlight(&b, t, color)
+		}
+		b.WriteString("</g>\n")
+	}
+
+	// Dimension lines
+	if dimensions {
+		b.WriteString("<g id=\"dimensions\">\n")
+		for _, ground := range c.Ground {
+			writeSVGDimensions(&b, ground)
+		}
+		b.WriteString("</g>\n")
+	}
+
+	b.WriteString("</g>\n</svg>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func writeSVGTrack(b *strings.Builder, t *Track, color string) {
+	for _, line := range t.Lines {
+		sin := math.Sin(line.Angle * math.Pi / 180)
+		cos := math.Cos(line.Angle * math.Pi / 180)
+		dx := sin * line.Length
+		dy := -cos * line.Length
+		fmt.Fprintf(b, "<path class=\"track-bars\" stroke=\"%v\" d=\"M %v %v L %v %v\"/>\n", html.EscapeString(color), svgNum(line.X), svgNum(line.Y), svgNum(line.X+dx), svgNum(line.Y+dy))
+		offsetY := -sin * svgGauge / 2
+		offsetX := -cos * svgGauge / 2
+		fmt.Fprintf(b, "<path class=\"track-iron\" d=\"M %v %v L %v %v M %v %v L %v %v\"/>\n",
+			svgNum(line.X+offsetX), svgNum(line.Y+offsetY), svgNum(line.X+offsetX+dx), svgNum(line.Y+offsetY+dy),
+			svgNum(line.X-offsetX), svgNum(line.Y-offsetY), svgNum(line.X-offsetX+dx), svgNum(line.Y-offsetY+dy))
+	}
+	for _, arc := range t.Arcs {
+		fmt.Fprintf(b, "<path class=\"track-bars\" stroke=\"%v\" d=\"%v\"/>\n", html.EscapeString(color), svgArc(arc.CenterX, arc.CenterY, arc.Radius, arc.StartAngle, arc.StartAngle+arc.TrackAngle))
+		d1 := svgArc(arc.CenterX, arc.CenterY, arc.Radius-svgGauge/2, arc.StartAngle, arc.StartAngle+arc.TrackAngle)
+		d2 := svgArc(arc.CenterX, arc.CenterY, arc.Radius+svgGauge/2, arc.StartAngle, arc.StartAngle+arc.TrackAngle)
+		fmt.Fprintf(b, "<path class=\"track-iron\" d=\"%v %v\"/>\n", d1, d2)
+	}
+	if len(t.Delimiters) != 0 {
+		var d []string
+		for _, delimiter := range t.Delimiters {
+			d = append(d, fmt.Sprintf("M %v %v L %v %v", svgNum(delimiter.X1), svgNum(delimiter.Y1), svgNum(delimiter.X2), svgNum(delimiter.Y2)))
+		}
+		fmt.Fprintf(b, "<path class=\"track-delimiter\" d=\"%v\"/>\n", strings.Join(d, " "))
+	}
+}
+
+func writeSVGHighlight(b *strings.Builder, t *Track, color string) {
+	for _, line := range t.Lines {
+		dx := math.Sin(line.Angle*math.Pi/180) * line.Length
+		dy := -math.Cos(line.Angle*math.Pi/180) * line.Length
+		fmt.Fprintf(b, "<path class=\"highlight\" stroke=\"%v\" d=\"M %v %v L %v %v\"/>\n", color, svgNum(line.X), svgNum(line.Y), svgNum(line.X+dx), svgNum(line.Y+dy))
+	}
+	for _, arc := range t.Arcs {
+		fmt.Fprintf(b, "<path class=\"highlight\" stroke=\"%v\" d=\"%v\"/>\n", color, svgArc(arc.CenterX, arc.CenterY, arc.Radius, arc.StartAngle, arc.StartAngle+arc.TrackAngle))
+	}
+}
+
+// Draws the width of the ground plate above it and its height left of it.
+func writeSVGDimensions(b *strings.Builder, ground *model.GroundPlate) {
+	left, top, right, bottom := ground.Left, ground.Top, ground.Left+ground.Width, ground.Top+ground.Height
+	if len(ground.Polygon) != 0 {
+		left, top, right, bottom = math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+		for _, p := range ground.Polygon {
+			left = math.Min(left, ground.Left+p.X)
+			right = math.Max(right, ground.Left+p.X)
+			top = math.Min(top, ground.Top+p.Y)
+			bottom = math.Max(bottom, ground.Top+p.Y)
+		}
+	}
+	const offset = 60
+	const tick = 20
+	// Horizontal dimension line
+	y := top - offset
+	fmt.Fprintf(b, "<path class=\"dimension\" d=\"M %v %v L %v %v M %v %v L %v %v M %v %v L %v %v\"/>\n",
+		svgNum(left), svgNum(y), svgNum(right), svgNum(y),
+		svgNum(left), svgNum(y-tick), svgNum(left), svgNum(y+tick),
+		svgNum(right), svgNum(y-tick), svgNum(right), svgNum(y+tick))
+	fmt.Fprintf(b, "<text class=\"dimension-text\" x=\"%v\" y=\"%v\" text-anchor=\"middle\">%v cm</text>\n", svgNum((left+right)/2), svgNum(y-8), svgNum((right-left)/10))
+	// Vertical dimension line
+	x := left - offset
+	fmt.Fprintf(b, "<path class=\"dimension\" d=\"M %v %v L %v %v M %v %v L %v %v M %v %v L %v %v\"/>\n",
+		svgNum(x), svgNum(top), svgNum(x), svgNum(bottom),
+		svgNum(x-tick), svgNum(top), svgNum(x+tick), svgNum(top),
+		svgNum(x-tick), svgNum(bottom), svgNum(x+tick), svgNum(bottom))
+	fmt.Fprintf(b, "<text class=\"dimension-text\" x=\"%v\" y=\"%v\" text-anchor=\"middle\" transform=\"rotate(-90 %v %v)\">%v cm</text>\n", svgNum(x-8), svgNum((top+bottom)/2), svgNum(x-8), svgNum((top+bottom)/2), svgNum((bottom-top)/10))
+}
+
+// Returns the SVG path of an arc. Angles are in degrees, zero pointing upwards.
+// See describeArc in render.js.
+func svgArc(x, y, radius, startAngle, endAngle float64) string {
+	startX, startY := svgPolarToCartesian(x, y, radius, endAngle)
+	endX, endY := svgPolarToCartesian(x, y, radius, startAngle)
+	largeArcFlag := "0"
+	if endAngle-startAngle > 180 {
+		largeArcFlag = "1"
+	}
+	return fmt.Sprintf("M %v %v A %v %v 0 %v 0 %v %v", svgNum(startX), svgNum(startY), svgNum(radius), svgNum(radius), largeArcFlag, svgNum(endX), svgNum(endY))
+}
+
+func svgPolarToCartesian(centerX, centerY, radius, angle float64) (float64, float64) {
+	rad := (angle - 90) * math.Pi / 180
+	return centerX + radius*math.Cos(rad), centerY + radius*math.Sin(rad)
+}
+
+// Formats a number with at most two decimal places.
+func svgNum(f float64) string {
+	r := math.Round(f*100) / 100
+	if r == 0 {
+		// Avoid printing -0
+		r = 0
+	}
+	return strconv.FormatFloat(r, 'f', -1, 64)
+}
@@ -5,6 +5,7 @@ async function init() {
     // Install event listeners before connecting to the server.
     go.addEventListener("canvas", (data) => {renderCanvas(data, document.getElementById("view2d"), document.getElementById("view2d-measure"), document.getElementById("view2d-ground"), document.getElementById("view2d-tracks"))});
     go.addEventListener("layout", (data) => {TrackDiagram.deserialize(data);})
+    go.addEventListener("state", (data) => {TrackDiagram.setState(data);})
     
     document.getElementById("select-switchtower").addEventListener("click", () => {
         document.getElementById("trackdiagram").style.display = "block";